@@ -0,0 +1,197 @@
+// influxclean audit package provides a tamper-evident log of drop statements
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Genesis is the previous hash of the first entry of an audit log
+const Genesis = "0000000000000000000000000000000000000000000000000000000000000000"
+
+// Results recorded for drop statements. A pending entry is recorded before
+// running a drop and followed by another one with its result
+const (
+	ResultOK      = "ok"
+	ResultDryrun  = "skipped by dry run"
+	ResultPending = "pending"
+)
+
+// Entry is a drop statement recorded in the audit log. Each entry includes
+// the hash of the previous one, so changing, removing or reordering entries
+// breaks the chain
+type Entry struct {
+	Time      time.Time `json:"time"`
+	Operator  string    `json:"operator"`
+	Server    string    `json:"server"`
+	Db        string    `json:"db"`
+	Statement string    `json:"statement"`
+	Tuples    int       `json:"tuples"`
+	Dryrun    bool      `json:"dryrun"`
+	Result    string    `json:"result"`
+	Prev      string    `json:"prev"`
+	Hash      string    `json:"hash"`
+}
+
+// Log is an append-only audit log file of JSON lines
+type Log struct {
+	mu       sync.Mutex
+	f        *os.File
+	operator string
+}
+
+// Open opens an audit log file for appending, creating it if needed
+func Open(path string) (*Log, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("could not open audit log: %w", err)
+	}
+	if _, err = lastHash(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &Log{f: f, operator: operator()}, nil
+}
+
+// Record appends an entry with the current time, the OS user and the hash of
+// the last entry in the file, and syncs it to disk. The file is locked while
+// reading the last hash and appending, so processes sharing the log cannot
+// fork the chain
+func (a *Log) Record(e Entry) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var err error
+	if err = lockFile(a.f); err != nil {
+		return fmt.Errorf("could not lock audit log: %w", err)
+	}
+	defer unlockFile(a.f)
+	if e.Prev, err = lastHash(a.f); err != nil {
+		return err
+	}
+	e.Time = time.Now().UTC()
+	e.Operator = a.operator
+	if e.Hash, err = e.hash(); err != nil {
+		return err
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if _, err = a.f.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("could not write audit log: %w", err)
+	}
+	return a.f.Sync()
+}
+
+// Close closes the audit log file
+func (a *Log) Close() error {
+	return a.f.Close()
+}
+
+// Verify reads an audit log and checks the hash chain of its entries,
+// returning the number of entries verified and an error with the line of the
+// first entry that does not match
+func Verify(r io.Reader) (int, error) {
+	var (
+		prev = Genesis
+		n    int
+		sc   = bufio.NewScanner(r)
+	)
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			return n, fmt.Errorf("line %d: empty line", line)
+		}
+		var e Entry
+		var d = json.NewDecoder(bytes.NewReader(sc.Bytes()))
+		d.DisallowUnknownFields()
+		if err := d.Decode(&e); err != nil {
+			return n, fmt.Errorf("line %d: %w", line, err)
+		}
+		if e.Prev != prev {
+			return n, fmt.Errorf("line %d: previous hash does not match the hash of line %d", line, line-1)
+		}
+		h, err := e.hash()
+		if err != nil {
+			return n, fmt.Errorf("line %d: %w", line, err)
+		}
+		if h != e.Hash {
+			return n, fmt.Errorf("line %d: entry does not match its hash", line)
+		}
+		prev = e.Hash
+		n++
+	}
+	return n, sc.Err()
+}
+
+// hash returns the SHA-256 of the entry encoded without its hash
+func (e Entry) hash() (string, error) {
+	e.Hash = ""
+	b, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	var sum = sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// lastHash returns the hash of the last entry in the file, or Genesis if empty
+func lastHash(f *os.File) (string, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	var size = fi.Size()
+	if size == 0 {
+		return Genesis, nil
+	}
+
+	// read backwards until the start of the last line is found
+	var tail []byte
+	for off := size; off > 0 && bytes.Count(tail, []byte("\n")) < 2; {
+		var chunk = int64(4096)
+		if off < chunk {
+			chunk = off
+		}
+		off -= chunk
+		var buf = make([]byte, chunk)
+		if _, err = f.ReadAt(buf, off); err != nil {
+			return "", fmt.Errorf("could not read audit log: %w", err)
+		}
+		tail = append(buf, tail...)
+	}
+	var lines = strings.Split(strings.TrimRight(string(tail), "\n"), "\n")
+	var e Entry
+	if err = json.Unmarshal([]byte(lines[len(lines)-1]), &e); err != nil || len(e.Hash) == 0 {
+		return "", fmt.Errorf("could not read the last entry of the audit log, check it with audit verify")
+	}
+	return e.Hash, nil
+}
+
+// operator returns the name of the OS user running the process
+func operator() string {
+	if u, err := user.Current(); err == nil && len(u.Username) > 0 {
+		return u.Username
+	}
+	for _, env := range []string{"USER", "USERNAME"} {
+		if name := os.Getenv(env); len(name) > 0 {
+			return name
+		}
+	}
+	return "unknown"
+}
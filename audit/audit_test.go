@@ -0,0 +1,99 @@
+// influxclean audit package provides a tamper-evident log of drop statements
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+package audit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// writeLog records n entries in a new audit log and returns its lines
+func writeLog(t *testing.T, n int) []string {
+	var path = filepath.Join(t.TempDir(), "audit.jsonl")
+	a, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		var e = Entry{Db: fmt.Sprintf("db%d", i), Statement: "DROP SERIES WHERE host='h1'", Tuples: 1, Result: ResultOK}
+		if err = a.Record(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a.Close()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.SplitAfter(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func TestVerify(t *testing.T) {
+	var lines = writeLog(t, 3)
+	var tests = []struct {
+		name    string
+		lines   []string
+		n       int
+		errLine string
+	}{
+		{"intact", lines, 3, ""},
+		{"modified", []string{lines[0], strings.Replace(lines[1], `"db1"`, `"db9"`, 1), lines[2]}, 1, "line 2:"},
+		{"removed", []string{lines[0], lines[2]}, 1, "line 2:"},
+		{"reordered", []string{lines[0], lines[2], lines[1]}, 1, "line 2:"},
+		{"truncated", []string{lines[0], lines[1], lines[2][:len(lines[2])/2]}, 2, "line 3:"},
+		{"empty line", []string{lines[0], "\n", lines[1]}, 1, "line 2:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var n, err = Verify(strings.NewReader(strings.Join(tt.lines, "")))
+			if n != tt.n {
+				t.Errorf("Verify() = %d entries, want %d", n, tt.n)
+			}
+			switch {
+			case len(tt.errLine) == 0 && err != nil:
+				t.Errorf("Verify() error = %v", err)
+			case len(tt.errLine) > 0 && (err == nil || !strings.HasPrefix(err.Error(), tt.errLine)):
+				t.Errorf("Verify() error = %v, want %s...", err, tt.errLine)
+			}
+		})
+	}
+}
+
+func TestRecordConcurrent(t *testing.T) {
+	var path = filepath.Join(t.TempDir(), "audit.jsonl")
+	var wg sync.WaitGroup
+	for p := 0; p < 4; p++ {
+		// each log has its own file like separate processes sharing it
+		a, err := Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer a.Close()
+		wg.Add(1)
+		go func(a *Log) {
+			defer wg.Done()
+			for i := 0; i < 25; i++ {
+				if err := a.Record(Entry{Statement: "DROP SERIES WHERE host='h1'", Result: ResultOK}); err != nil {
+					t.Error(err)
+				}
+			}
+		}(a)
+	}
+	wg.Wait()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if n, err := Verify(f); err != nil || n != 100 {
+		t.Errorf("Verify() = %d, %v, want 100 entries", n, err)
+	}
+}
@@ -0,0 +1,23 @@
+// influxclean audit package provides a tamper-evident log of drop statements
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd && !solaris && !windows
+
+package audit
+
+import (
+	"os"
+)
+
+// lockFile does nothing where file locks are not supported, so only the
+// records of a single process are serialized
+func lockFile(f *os.File) error {
+	return nil
+}
+
+// unlockFile does nothing where file locks are not supported
+func unlockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,24 @@
+// influxclean audit package provides a tamper-evident log of drop statements
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd || solaris
+
+package audit
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// lockFile takes an exclusive lock on the file, waiting for other processes
+func lockFile(f *os.File) error {
+	return unix.Flock(int(f.Fd()), unix.LOCK_EX)
+}
+
+// unlockFile releases the lock taken by lockFile
+func unlockFile(f *os.File) error {
+	return unix.Flock(int(f.Fd()), unix.LOCK_UN)
+}
@@ -0,0 +1,159 @@
+// influxclean backup package provides files with series data saved before drops
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+package backup
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Context lines of the influx_inspect export format, which influx -import reads too
+const (
+	headerDDL     = "# DDL"
+	headerDML     = "# DML"
+	contextDb     = "# CONTEXT-DATABASE:"
+	contextRp     = "# CONTEXT-RETENTION-POLICY:"
+	fileTimestamp = "20060102T150405Z"
+)
+
+// Writer writes series data as line protocol with nanosecond timestamps in the
+// influx_inspect export format, with the database and retention policy of the
+// lines that follow given by context lines
+type Writer struct {
+	mu     sync.Mutex
+	f      *os.File
+	w      *bufio.Writer
+	db, rp string
+	lines  int
+}
+
+// Create creates a new backup file named after the current time in dir
+func Create(dir string) (*Writer, error) {
+	var name = fmt.Sprintf("influxclean-%s.lp", time.Now().UTC().Format(fileTimestamp))
+	f, err := os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("could not create backup file: %w", err)
+	}
+	var bw = &Writer{f: f, w: bufio.NewWriter(f)}
+	if _, err = bw.w.WriteString(headerDML + "\n"); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return bw, nil
+}
+
+// Path returns the path of the backup file
+func (bw *Writer) Path() string {
+	return bw.f.Name()
+}
+
+// Lines returns the number of point lines written
+func (bw *Writer) Lines() int {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	return bw.lines
+}
+
+// Write writes the point lines of database db and retention policy rp,
+// preceded by context lines if they differ from those of the previous lines
+func (bw *Writer) Write(db, rp string, lines ...string) error {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+
+	if db != bw.db || rp != bw.rp {
+		fmt.Fprintf(bw.w, "%s%s\n%s%s\n", contextDb, db, contextRp, rp)
+		bw.db, bw.rp = db, rp
+	}
+	for _, line := range lines {
+		if _, err := bw.w.WriteString(line + "\n"); err != nil {
+			return fmt.Errorf("could not write backup file: %w", err)
+		}
+	}
+	bw.lines += len(lines)
+	return nil
+}
+
+// Sync flushes the lines written and syncs them to disk
+func (bw *Writer) Sync() error {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+
+	if err := bw.w.Flush(); err != nil {
+		return fmt.Errorf("could not write backup file: %w", err)
+	}
+	return bw.f.Sync()
+}
+
+// Close syncs and closes the backup file, removing it if no lines were written
+func (bw *Writer) Close() error {
+	var err = bw.Sync()
+	if cerr := bw.f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && bw.Lines() == 0 {
+		err = os.Remove(bw.f.Name())
+	}
+	return err
+}
+
+// Read reads a file in the influx_inspect export format calling fn with the
+// database, retention policy and point lines of each batch of up to size
+// lines. Statements in the DDL section and comments are skipped
+func Read(r io.Reader, size int, fn func(db, rp string, lines []string) error) error {
+	var (
+		db, rp string
+		batch  []string
+		ddl    bool
+		sc     = bufio.NewScanner(r)
+	)
+	var flush = func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if len(db) == 0 {
+			return fmt.Errorf("point lines found before a %s line", contextDb)
+		}
+		var err = fn(db, rp, batch)
+		batch = nil
+		return err
+	}
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		var text = strings.TrimSpace(sc.Text())
+		var err error
+		switch {
+		case len(text) == 0:
+		case text == headerDDL:
+			ddl = true
+		case text == headerDML:
+			ddl = false
+		case strings.HasPrefix(text, contextDb):
+			err = flush()
+			db = strings.TrimSpace(strings.TrimPrefix(text, contextDb))
+		case strings.HasPrefix(text, contextRp):
+			err = flush()
+			rp = strings.TrimSpace(strings.TrimPrefix(text, contextRp))
+		case strings.HasPrefix(text, "#"), ddl:
+		default:
+			if batch = append(batch, text); len(batch) >= size {
+				err = flush()
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	return flush()
+}
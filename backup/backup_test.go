@@ -0,0 +1,77 @@
+// influxclean backup package provides files with series data saved before drops
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+package backup
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRead(t *testing.T) {
+	var tests = []struct {
+		name  string
+		input string
+		want  []string
+		err   bool
+	}{
+		{
+			name: "export with ddl",
+			input: `# DDL
+CREATE DATABASE telegraf WITH NAME autogen
+# DML
+# CONTEXT-DATABASE:telegraf
+# CONTEXT-RETENTION-POLICY:autogen
+# writes
+cpu,host=h1 usage=1 1
+cpu,host=h1 usage=2 2
+
+cpu,host=h1 usage=3 3
+# CONTEXT-RETENTION-POLICY:weekly
+cpu,host=h1 usage=4 4
+`,
+			want: []string{
+				"telegraf.autogen: cpu,host=h1 usage=1 1|cpu,host=h1 usage=2 2",
+				"telegraf.autogen: cpu,host=h1 usage=3 3",
+				"telegraf.weekly: cpu,host=h1 usage=4 4",
+			},
+		},
+		{
+			name:  "lines without database",
+			input: "# DML\ncpu,host=h1 usage=1 1\n",
+			err:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			var err = Read(strings.NewReader(tt.input), 2, func(db, rp string, lines []string) error {
+				got = append(got, db+"."+rp+": "+strings.Join(lines, "|"))
+				return nil
+			})
+			if (err != nil) != tt.err {
+				t.Fatalf("Read() error = %v, want error %v", err, tt.err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Read() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCloseRemovesEmpty(t *testing.T) {
+	var dir = t.TempDir()
+	var w, err = Create(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Create(dir); err != nil {
+		t.Errorf("Create() after removing empty file: %v", err)
+	}
+}
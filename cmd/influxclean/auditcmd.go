@@ -0,0 +1,70 @@
+// influxclean main package provides cli and starts DB cleaning jobs
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/tesibelda/influxclean/audit"
+)
+
+// runAudit runs the audit subcommands
+func runAudit(args []string) int {
+	if len(args) == 0 || args[0] != "verify" {
+		fmt.Fprintln(os.Stderr, "Usage: influxclean audit verify [flags] [audit log files]")
+		return exitConfig
+	}
+	return runAuditVerify(args[1:])
+}
+
+// runAuditVerify checks the hash chain of audit log files
+func runAuditVerify(args []string) int {
+	var fs = newFlagSet("audit verify", descAudit+`
+
+Checks that no entry of the audit log files was changed, removed or
+reordered by verifying the hash chain of their entries. Without files, the
+audit_log of the configuration is verified.
+
+Exit codes: 0 audit logs are intact, 1 usage error, unreadable or tampered log`)
+	var cfgopts = addConfigFlags(fs)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	var files = fs.Args()
+	if len(files) == 0 {
+		var cfg, err = cfgopts.load()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitConfig
+		}
+		if len(cfg.Audit_log) == 0 {
+			fmt.Fprintln(os.Stderr, "No audit log files given and no audit_log in configuration")
+			return exitConfig
+		}
+		files = []string{cfg.Audit_log}
+	}
+
+	var code = exitOK
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			code = exitConfig
+			continue
+		}
+		n, err := audit.Verify(f)
+		f.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Audit log %s is not intact after %d valid entries: %v\n", file, n, err)
+			code = exitConfig
+			continue
+		}
+		fmt.Printf("Audit log %s is intact with %d entries\n", file, n)
+	}
+	return code
+}
@@ -0,0 +1,61 @@
+// influxclean main package provides cli and starts DB cleaning jobs
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/tesibelda/influxclean/config"
+)
+
+// runConfig runs config subcommands
+func runConfig(args []string) int {
+	if len(args) == 0 || args[0] != "show" {
+		fmt.Fprintln(os.Stderr, "Usage: influxclean config show [--effective] [--config file] [--config-dir dir]")
+		return exitConfig
+	}
+	return runConfigShow(args[1:])
+}
+
+// runConfigShow prints the merged configuration files, with defaults and
+// templates resolved if effective is set
+func runConfigShow(args []string) int {
+	var (
+		cfg      *config.InfluxCleanConfig
+		cfgfiles []string
+		err      error
+	)
+
+	var fs = newFlagSet("config show", "Show the merged configuration files or, with --effective, the\nconfiguration with defaults and templates resolved.\n\nExit codes: 0 configuration shown, 1 configuration error")
+	var cfgopts = addConfigFlags(fs)
+	var format = fs.String("format", config.FormatTOML, "output format: toml, yaml or json")
+	var effective = fs.Bool("effective", false, "show the effective config with defaults and templates resolved")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	if cfgfiles, err = cfgopts.files(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitConfig
+	}
+	switch *effective {
+	case true:
+		cfg, err = config.LoadFiles(cfgfiles, cfgopts.cfgfmt)
+	default:
+		cfg, err = config.MergeFiles(cfgfiles, cfgopts.cfgfmt)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitConfig
+	}
+	cfg.Redact()
+	if err = cfg.Encode(os.Stdout, *format); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitConfig
+	}
+	return exitOK
+}
@@ -0,0 +1,74 @@
+// influxclean main package provides cli and starts DB cleaning jobs
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/tesibelda/influxclean/datastore/influxdb1"
+)
+
+// previewPageSize is the number of candidate series shown per page
+const previewPageSize = 20
+
+// terminalConfirmer previews candidate series and asks the user to confirm drops
+type terminalConfirmer struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// confirm shows the candidates of a job in measurement m of a database, or in
+// all its measurements if m is empty, page by page and reports whether the
+// user typed the database name or yes to drop them
+func (tc *terminalConfirmer) confirm(job, db, m string, tags, series []string) bool {
+	var from = "all measurements"
+	if len(m) > 0 {
+		from = "measurement " + m
+	}
+	fmt.Fprintf(tc.out, "\noldseries job %s will drop %d series from %s in %s db (%s):\n",
+		job,
+		len(series),
+		from,
+		db,
+		strings.Join(tags, ", "),
+	)
+	for page := 0; ; page++ {
+		var begin = page * previewPageSize
+		var end = begin + previewPageSize
+		if end > len(series) {
+			end = len(series)
+		}
+		for i := begin; i < end; i++ {
+			fmt.Fprintf(tc.out, "  %4d  %s\n",
+				i+1,
+				strings.ReplaceAll(series[i], influxdb1.Separator, ", "),
+			)
+		}
+		var more = end < len(series)
+		switch more {
+		case true:
+			fmt.Fprintf(tc.out, "Showing %d of %d. Press enter for more, type %s or yes to drop, anything else to skip: ",
+				end,
+				len(series),
+				db,
+			)
+		default:
+			fmt.Fprintf(tc.out, "Type %s or yes to drop, anything else to skip: ", db)
+		}
+		var answer, err = tc.in.ReadString('\n')
+		answer = strings.TrimSpace(answer)
+		switch {
+		case answer == db || answer == "yes":
+			return true
+		case answer == "" && more && err == nil:
+			continue
+		}
+		return false
+	}
+}
@@ -0,0 +1,212 @@
+// influxclean main package provides cli and starts DB cleaning jobs
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/tesibelda/influxclean/config"
+	"github.com/tesibelda/influxclean/log"
+)
+
+// configOptions holds the flags selecting the configuration files
+type configOptions struct {
+	fs      *flag.FlagSet
+	cfgfile string
+	cfgdir  string
+	cfgfmt  string
+}
+
+// addConfigFlags adds the configuration file flags to a flag set
+func addConfigFlags(fs *flag.FlagSet) *configOptions {
+	var o = &configOptions{fs: fs}
+	fs.StringVar(&o.cfgfile, "config", "influxclean.toml", "config file")
+	fs.StringVar(&o.cfgdir, "config-dir", "", "directory with config files to merge")
+	fs.StringVar(&o.cfgfmt, "config-format", "", "config file format: toml, yaml or json (default by file extension)")
+	return o
+}
+
+// files returns the configuration files to load, which are the ones in the
+// config directory if given, preceded by the config file if it was explicitly set
+func (o *configOptions) files() ([]string, error) {
+	if len(o.cfgdir) == 0 {
+		return []string{o.cfgfile}, nil
+	}
+	var files, err = config.DirFiles(o.cfgdir)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading configuration directory: %w", err)
+	}
+	o.fs.Visit(func(f *flag.Flag) {
+		if f.Name == "config" {
+			files = append([]string{o.cfgfile}, files...)
+		}
+	})
+	return files, nil
+}
+
+// load loads and parses the configuration files
+func (o *configOptions) load() (*config.InfluxCleanConfig, error) {
+	var files, err = o.files()
+	if err != nil {
+		return nil, err
+	}
+	return config.LoadFiles(files, o.cfgfmt)
+}
+
+// selectOptions holds the flags selecting the jobs to run
+type selectOptions struct {
+	sel      config.Selection
+	selector string
+}
+
+// addSelectFlags adds the job selection flags to a flag set
+func addSelectFlags(fs *flag.FlagSet) *selectOptions {
+	var o = &selectOptions{}
+	fs.Var((*stringList)(&o.sel.Jobs), "job", "run only the job with this name (repeatable)")
+	fs.Var((*stringList)(&o.sel.Servers), "server", "run only on the server with this url or name (repeatable)")
+	fs.Var((*stringList)(&o.sel.Databases), "db", "run only on this database (repeatable)")
+	fs.StringVar(&o.selector, "selector", "", "run only jobs with these labels (label=value,...)")
+	return o
+}
+
+// apply restricts the configuration to the selected jobs
+func (o *selectOptions) apply(cfg *config.InfluxCleanConfig) error {
+	var err error
+	if o.sel.Selector, err = config.ParseSelector(o.selector); err != nil {
+		return err
+	}
+	return cfg.Select(o.sel)
+}
+
+// addServerFlags adds the flags setting an influxdb1 server connection without
+// configuration files. Passwords are read from the environment or a file
+func addServerFlags(fs *flag.FlagSet, inf *config.Influxdb1Info, url string) {
+	fs.StringVar(&inf.Url, "url", url, "influxdb1 url")
+	fs.StringVar(&inf.User, "user", "", "influxdb1 user")
+	fs.StringVar(&inf.Env_password, "env-password", "", "environment variable with the influxdb1 password")
+	fs.StringVar(&inf.Password_file, "password-file", "", "file with the influxdb1 password")
+	inf.Insecure_skip_verify = new(bool)
+	fs.BoolVar(inf.Insecure_skip_verify, "insecure-skip-verify", false, "skip tls certificate verification")
+}
+
+// logOptions holds the flags setting log format, level and output
+type logOptions struct {
+	fs     *flag.FlagSet
+	opts   log.Options
+	debug  bool
+	sizeMB int64
+}
+
+// addLogFlags adds the log flags to a flag set, with the given default and
+// usage for --debug, which sets the level to debug or info unless --log-level
+// is given
+func addLogFlags(fs *flag.FlagSet, debug bool, debugUsage string) *logOptions {
+	var o = &logOptions{fs: fs}
+	fs.BoolVar(&o.debug, "debug", debug, debugUsage)
+	fs.StringVar(&o.opts.Format, "log-format", "", "log format: text, json or logfmt (default text)")
+	fs.StringVar(&o.opts.Level, "log-level", "", "log level: trace, debug, info, warn or error (default by --debug)")
+	fs.StringVar(&o.opts.Output, "log-output", "", "log output: stdout, file, syslog or journald (default file if --log-file is set, else stdout)")
+	fs.StringVar(&o.opts.File, "log-file", "", "file to log to")
+	fs.Int64Var(&o.sizeMB, "log-max-size", 10, "size in MB at which the log file is rotated, 0 to never rotate")
+	fs.IntVar(&o.opts.MaxBackups, "log-max-backups", 3, "number of rotated log files kept")
+	fs.StringVar(&o.opts.Syslog, "log-syslog", "", "syslog address as udp://host:port, tcp://host:port or unix:///path (default local syslog)")
+	fs.StringVar(&o.opts.Facility, "log-facility", "", "syslog facility (default daemon)")
+	fs.StringVar(&o.opts.Tag, "log-tag", "", "syslog tag and journald identifier (default influxclean)")
+	return o
+}
+
+// options returns the log options given by the flags, taking the ones not set
+// in the command line from the log section of cfg if not nil
+func (o *logOptions) options(cfg *config.InfluxCleanConfig) log.Options {
+	var opts = o.opts
+	var set = make(map[string]bool)
+	o.fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	opts.MaxSize = o.sizeMB
+	if cfg != nil {
+		var li = cfg.Log
+		for _, f := range []struct {
+			flag string
+			dst  *string
+			src  string
+		}{
+			{"log-format", &opts.Format, li.Format},
+			{"log-output", &opts.Output, li.Output},
+			{"log-file", &opts.File, li.File},
+			{"log-syslog", &opts.Syslog, li.Syslog},
+			{"log-facility", &opts.Facility, li.Facility},
+			{"log-tag", &opts.Tag, li.Tag},
+		} {
+			if !set[f.flag] && len(f.src) > 0 {
+				*f.dst = f.src
+			}
+		}
+		if !set["log-level"] && !set["debug"] && len(li.Level) > 0 {
+			opts.Level = li.Level
+		}
+		if !set["log-max-size"] && li.Max_size > 0 {
+			opts.MaxSize = li.Max_size
+		}
+		if !set["log-max-backups"] && li.Max_backups > 0 {
+			opts.MaxBackups = li.Max_backups
+		}
+	}
+	if len(opts.Level) == 0 {
+		opts.Level = "info"
+		if o.debug {
+			opts.Level = "debug"
+		}
+	}
+	opts.MaxSize *= 1024 * 1024
+	return opts
+}
+
+// logger returns a logger with the log options, see options
+func (o *logOptions) logger(cfg *config.InfluxCleanConfig) (*log.Logger, error) {
+	return log.New(o.options(cfg))
+}
+
+// newFlagSet returns a flag set for a subcommand with its usage description,
+// to be parsed with parseFlags
+func newFlagSet(name, desc string) *flag.FlagSet {
+	var fs = flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: influxclean %s [flags]\n\n%s\n\nFlags:\n", name, desc)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags parses the flags of a subcommand and reports whether it should go
+// on, returning otherwise exitOK if help was asked or exitConfig if the flags
+// are wrong, which the flag set has already reported
+func parseFlags(fs *flag.FlagSet, args []string) (int, bool) {
+	switch err := fs.Parse(args); {
+	case err == nil:
+		return exitOK, true
+	case errors.Is(err, flag.ErrHelp):
+		return exitOK, false
+	}
+	return exitConfig, false
+}
+
+// stringList is a flag value collecting repeated string flags
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
@@ -0,0 +1,179 @@
+// influxclean main package provides cli and starts DB cleaning jobs
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/tesibelda/influxclean/config"
+	"github.com/tesibelda/influxclean/jobs"
+)
+
+// initTemplate is the commented TOML configuration proposed by init
+var initTemplate = template.Must(template.New("init").Funcs(template.FuncMap{
+	"quote": strconv.Quote,
+	"list":  quoteList,
+	"join":  strings.Join,
+}).Parse(`# influxclean config proposed by influxclean init
+# from {{.Server.Url}} on {{.Date}}
+#
+# Review each job and run influxclean plan with this file. Jobs are created
+# with dryrun = "always" so they never drop series: remove that line from the
+# jobs you want influxclean apply to run for real.
+[[influxdb1]]
+  url = {{quote .Server.Url}}
+  # credentials read from environment variables
+  # env_user = "INFLUX_USER"
+  # env_password = "INFLUX_PWD"
+{{- with .Server.User}}
+  user = {{quote .}}
+{{- end}}
+{{- with .Server.Env_password}}
+  env_password = {{quote .}}
+{{- end}}
+{{- with .Server.Password_file}}
+  password_file = {{quote .}}
+{{- end}}
+  ## Use TLS but skip chain & host verification (default false)
+  insecure_skip_verify = {{.Server.SkipsVerify}}
+  # tag values never dropped by any job of this server
+  # [influxdb1.protect]
+  #   host = ["mycriticalserver01"]
+{{- range .Databases}}
+
+  # database {{.Name}} with {{.Measurements}} measurements
+{{- if not .Suggestions}}
+  # no known Telegraf layout found, use influxclean inspect to write jobs
+{{- end}}
+{{- range .Suggestions}}
+
+  # {{.Layout}} found in {{join .Matched ", "}}
+{{- range .Problems}}
+  # WARNING: {{.}}
+{{- end}}
+{{- with .Job}}
+  [[influxdb1.oldseries]]
+    name = {{quote .Name}}
+    dryrun = {{quote .Dryrun}}
+    databases = {{list .Databases}}
+    # preset sets measurement, field and tags, which may be overridden
+    preset = {{quote .Preset}}
+{{- if .DropsFromAll}}
+    # series are dropped from all measurements of the database
+    drop_from_all = true
+{{- end}}
+    # no data in current_window means the series is old
+    history_window = {{list .History_window}}
+    current_window = {{list .Current_window}}
+{{- end}}
+{{- end}}
+{{- end}}
+`))
+
+// initData is the data rendered by initTemplate
+type initData struct {
+	Server    config.Influxdb1Info
+	Date      string
+	Databases []jobs.DiscoveredDatabase
+}
+
+// runInit proposes a configuration from the databases found in a server
+func runInit(args []string) int {
+	var fs = newFlagSet("init", descInit+`
+
+Connects to the server, lists its databases, measurements and tag keys, and
+writes a commented TOML configuration with oldseries jobs suggested for the
+Telegraf layouts found (Linux and Windows hosts, Docker containers,
+Kubernetes pods and vSphere virtual machines). Jobs are created in dry run
+mode. Example:
+
+  influxclean init --url http://localhost:8086 --output influxclean.toml
+
+Exit codes: 0 configuration written, 1 usage, connection or query error`)
+	var (
+		inf    config.Influxdb1Info
+		dbs    stringList
+		output string
+		force  bool
+	)
+	addServerFlags(fs, &inf, "http://localhost:8086")
+	fs.Var(&dbs, "db", "database to discover (repeatable, default all)")
+	fs.StringVar(&output, "output", "", "file to write the configuration to (default standard output)")
+	fs.BoolVar(&force, "force", false, "overwrite the output file if it exists")
+	var logopts = addLogFlags(fs, false, "display queries and results")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	var cfg = config.NewInfluxCleanConfig()
+	cfg.Influxdb1 = []config.Influxdb1Info{inf}
+	if err := cfg.Prepare(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitConfig
+	}
+	lg, err := logopts.logger(nil)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitConfig
+	}
+	defer lg.Close()
+	found, err := jobs.Discover(cfg.Influxdb1[0], dbs, lg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitConfig
+	}
+
+	var buf bytes.Buffer
+	err = initTemplate.Execute(&buf, initData{
+		Server:    inf,
+		Date:      time.Now().Format("2006-01-02"),
+		Databases: found,
+	})
+	if err == nil {
+		err = config.NewInfluxCleanConfig().ReadFormat(bytes.NewReader(buf.Bytes()), config.FormatTOML)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Proposed configuration is not valid: %v\n", err)
+		return exitConfig
+	}
+
+	if len(output) == 0 {
+		os.Stdout.Write(buf.Bytes())
+		return exitOK
+	}
+	var flags = os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if force {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	f, err := os.OpenFile(output, flags, 0o600)
+	if err == nil {
+		_, err = f.Write(buf.Bytes())
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not write configuration: %v\n", err)
+		return exitConfig
+	}
+	fmt.Fprintf(os.Stderr, "Configuration written to %s\n", output)
+	return exitOK
+}
+
+// quoteList returns a TOML array of quoted strings
+func quoteList(list []string) string {
+	var quoted = make([]string, 0, len(list))
+	for _, s := range list {
+		quoted = append(quoted, strconv.Quote(s))
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
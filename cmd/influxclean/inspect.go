@@ -0,0 +1,161 @@
+// influxclean main package provides cli and starts DB cleaning jobs
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/tesibelda/influxclean/config"
+	"github.com/tesibelda/influxclean/internal/timewindow"
+	"github.com/tesibelda/influxclean/jobs"
+)
+
+// runInspect prints the cardinality and staleness of measurements in a server
+func runInspect(args []string) int {
+	var fs = newFlagSet("inspect", descInspect+`
+
+Lists the measurements of the selected databases with their series
+cardinality, the cardinality of each tag key and, for the tag keys given with
+--tags, how many values have no data in the --window. It only runs queries.
+The server is taken from --url or, if not given, from the configuration
+server named by --server. Example:
+
+  influxclean inspect --url http://localhost:8086 --db telegraf --tags host
+
+Exit codes: 0 report printed, 1 usage, configuration or query error`)
+	var (
+		cfgopts = addConfigFlags(fs)
+		inf     config.Influxdb1Info
+		o       jobs.InspectOptions
+		server  string
+		tags    string
+		window  string
+	)
+	addServerFlags(fs, &inf, "")
+	fs.StringVar(&server, "server", "", "name or url of the configuration server to inspect")
+	fs.Var((*stringList)(&o.Databases), "db", "database to inspect (repeatable, default all)")
+	fs.StringVar(&o.Rp, "rp", "", "retention policy")
+	fs.Var((*stringList)(&o.Measurements), "measurement", "measurement to inspect (repeatable, default all)")
+	fs.StringVar(&o.MeasurementRegex, "measurement-regex", "", "regular expression matching the measurements to inspect")
+	fs.StringVar(&tags, "tags", "", "comma separated tag keys whose values are checked for staleness")
+	fs.StringVar(&o.Field, "field", "*", "field with data to detect current values")
+	fs.StringVar(&window, "window", "72h,0s", "window as begin,end in which values with data are current")
+	var logopts = addLogFlags(fs, false, "display queries and results")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	o.Tags = splitList(tags)
+	o.Window = splitList(window)
+	if err := checkWindow(o.Window); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitConfig
+	}
+	inf, cfg, err := inspectServer(cfgopts, inf, server)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitConfig
+	}
+
+	lg, err := logopts.logger(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitConfig
+	}
+	defer lg.Close()
+	stats, err := jobs.Inspect(inf, o, lg)
+	printStats(os.Stdout, stats, o.Window)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitConfig
+	}
+	return exitOK
+}
+
+// inspectServer returns the server to inspect and the config it comes from,
+// which is the one given by the server flags if --url was set or else the
+// configuration server matching name. If the configuration cannot be loaded
+// and name is a url, it is used as --url
+func inspectServer(
+	cfgopts *configOptions,
+	inf config.Influxdb1Info,
+	name string,
+) (config.Influxdb1Info, *config.InfluxCleanConfig, error) {
+	var cfg *config.InfluxCleanConfig
+	var err error
+	if len(inf.Url) == 0 {
+		cfg, err = cfgopts.load()
+		switch {
+		case err == nil:
+		case strings.Contains(name, "://"):
+			inf.Url = name
+		default:
+			return inf, nil, err
+		}
+	}
+	if len(inf.Url) > 0 {
+		cfg = config.NewInfluxCleanConfig()
+		cfg.Influxdb1 = []config.Influxdb1Info{inf}
+		if err = cfg.Prepare(); err != nil {
+			return inf, nil, err
+		}
+		return cfg.Influxdb1[0], cfg, nil
+	}
+	for _, s := range cfg.Influxdb1 {
+		if s.Url == name || (len(s.Name) > 0 && s.Name == name) {
+			return s, cfg, nil
+		}
+	}
+	if len(name) == 0 && len(cfg.Influxdb1) == 1 {
+		return cfg.Influxdb1[0], cfg, nil
+	}
+	if len(name) == 0 {
+		return inf, nil, fmt.Errorf("Use --server to choose one of the %d configured servers or --url", len(cfg.Influxdb1))
+	}
+	return inf, nil, fmt.Errorf("Server %s not found in configuration", name)
+}
+
+// checkWindow returns an error unless w is a begin,end pair of time bounds
+func checkWindow(w []string) error {
+	if len(w) != 2 {
+		return fmt.Errorf("Window must have begin and end separated by a comma")
+	}
+	for _, b := range w {
+		if _, err := timewindow.ParseBound(b); err != nil {
+			return fmt.Errorf("Invalid window: %w", err)
+		}
+	}
+	return nil
+}
+
+// printStats prints the cardinality of each database as a table
+func printStats(out io.Writer, stats []jobs.DatabaseStats, window []string) {
+	for _, ds := range stats {
+		fmt.Fprintf(out, "Database %s\n", ds.Name)
+		var tw = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(tw, "MEASUREMENT\tSERIES\tTAG KEY\tVALUES\tNO DATA FROM %s TO %s\n", window[0], window[1])
+		for _, ms := range ds.Measurements {
+			fmt.Fprintf(tw, "%s\t%d\t\t\t\n", ms.Name, ms.Series)
+			for _, ts := range ms.Tags {
+				var stale string
+				if ts.Checked > 0 {
+					stale = fmt.Sprintf("%d/%d (%.1f%%)",
+						ts.Stale,
+						ts.Checked,
+						100*float64(ts.Stale)/float64(ts.Checked),
+					)
+				}
+				fmt.Fprintf(tw, "\t\t%s\t%d\t%s\n", ts.Key, ts.Values, stale)
+			}
+		}
+		tw.Flush()
+		fmt.Fprintln(out)
+	}
+}
@@ -6,54 +6,109 @@
 package main
 
 import (
-	"flag"
 	"fmt"
 	"os"
-
-	"github.com/tesibelda/influxclean/config"
-	"github.com/tesibelda/influxclean/jobs"
-	"github.com/tesibelda/influxclean/log"
+	"strings"
 )
 
 var Version string = ""
 
+// Exit codes shared by all subcommands
+const (
+	exitOK      = 0 // command completed successfully
+	exitConfig  = 1 // usage, configuration or validation error
+	exitJobs    = 2 // one or more jobs failed, others may have dropped series
+	exitAborted = 3 // drops stopped by a safety guard such as a missing confirmation
+	exitNothing = 4 // jobs completed without finding series to drop
+)
+
+// command is a cli subcommand
+type command struct {
+	run  func(args []string) int
+	desc string
+}
+
+// Subcommand descriptions
+const (
+	descRun       = "run jobs, in dry run mode unless --dryrun=false and confirmed"
+	descPlan      = "run jobs in dry run mode showing what would be dropped"
+	descApply     = "run jobs dropping series, after confirmation or --yes"
+	descOldSeries = "run an oldseries job given by flags, without config files"
+	descInspect   = "report series cardinality and stale tag values of a server"
+	descInit      = "propose a configuration from the databases of a server"
+	descValidate  = "validate configuration, optionally against the servers"
+	descRestore   = "write back series saved before drops"
+	descAudit     = "verify the audit log of drop statements"
+	descConfig    = "show the merged or effective configuration"
+	descVersion   = "show version and exit"
+)
+
+var commands = map[string]command{
+	"run":       {runRun, descRun},
+	"plan":      {runPlan, descPlan},
+	"apply":     {runApply, descApply},
+	"oldseries": {runOldSeries, descOldSeries},
+	"inspect":   {runInspect, descInspect},
+	"init":      {runInit, descInit},
+	"validate":  {runValidate, descValidate},
+	"restore":   {runRestore, descRestore},
+	"audit":     {runAudit, descAudit},
+	"config":    {runConfig, descConfig},
+	"version":   {runVersion, descVersion},
+}
+
+var commandOrder = []string{"run", "plan", "apply", "oldseries", "inspect", "init", "validate", "restore", "audit", "config", "version"}
+
 func main() {
-	var (
-		f       *os.File
-		cfgfile string
-		ecode   int
-		err     error
-		dryrun  bool
-		debug   bool
-	)
-
-	// cli parameters
-	flag.BoolVar(&debug, "debug", true, "display queries and results")
-	flag.BoolVar(&dryrun, "dryrun", true, "dry run does not drop any series")
-	flag.StringVar(&cfgfile, "config", "influxclean.toml", "config file")
-	var showVersion = flag.Bool("version", false, "show version and exit")
-	flag.Parse()
-	if *showVersion {
-		fmt.Println("influxclean", Version)
-		os.Exit(0)
-	}
+	var args = os.Args[1:]
 
-	// config file
-	if f, err = os.Open(cfgfile); err != nil {
-		fmt.Println("Error opening configuration file:", err)
-		os.Exit(1)
+	// without subcommand behave as run for compatibility with previous versions
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		if len(args) > 0 && (args[0] == "-version" || args[0] == "--version") {
+			os.Exit(runVersion(nil))
+		}
+		os.Exit(runLegacy(args))
+	}
+	if args[0] == "help" {
+		usage()
+		os.Exit(exitOK)
 	}
-	var cfg = config.NewInfluxCleanConfig()
-	if err = cfg.ReadFile(f); err != nil {
-		fmt.Fprintf(os.Stderr, "Could not load config in file %s: %s", cfgfile, err.Error())
-		os.Exit(1)
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %s\n\n", args[0])
+		usage()
+		os.Exit(exitConfig)
 	}
-	f.Close()
+	os.Exit(cmd.run(args[1:]))
+}
 
-	// run cleanup jobs
-	var l = log.NewLogger(debug)
-	if err = jobs.RunJobs(cfg, l, dryrun); err != nil {
-		ecode = 2
+// usage prints the list of subcommands and exit codes
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: influxclean <command> [flags]")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	for _, name := range commandOrder {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, commands[name].desc)
 	}
-	os.Exit(ecode)
+	fmt.Fprintln(os.Stderr, "\nUse influxclean <command> -h for the flags of each command.")
+	fmt.Fprintln(os.Stderr, "\nExit codes:")
+	fmt.Fprintln(os.Stderr, "  0  success, series dropped or found to drop in dry run")
+	fmt.Fprintln(os.Stderr, "  1  usage, configuration or validation error")
+	fmt.Fprintln(os.Stderr, "  2  partial failure, one or more jobs failed")
+	fmt.Fprintln(os.Stderr, "  3  aborted by a safety guard, drops not confirmed")
+	fmt.Fprintln(os.Stderr, "  4  nothing to do, no series found to drop (0 without command)")
+}
+
+// runLegacy runs as the run command for invocations without subcommand, which
+// exit with 0 when there is nothing to do as in previous versions
+func runLegacy(args []string) int {
+	if code := runRun(args); code != exitNothing {
+		return code
+	}
+	return exitOK
+}
+
+// runVersion prints the version
+func runVersion(args []string) int {
+	fmt.Println("influxclean", Version)
+	return exitOK
 }
@@ -0,0 +1,94 @@
+// influxclean main package provides cli and starts DB cleaning jobs
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/tesibelda/influxclean/config"
+)
+
+// runOldSeries runs an oldseries job built from command line flags
+func runOldSeries(args []string) int {
+	var fs = newFlagSet("oldseries", descOldSeries+`
+
+Builds a single oldseries job from the flags, without configuration files,
+and runs it in dry run mode unless --dryrun=false and confirmed. Example:
+
+  influxclean oldseries --url http://localhost:8086 --db telegraf \
+    --measurement win_system --field Processor_Queue_Length --tags host \
+    --current 72h,1m
+
+Exit codes: 0 series dropped or found to drop, 1 usage or configuration
+error, 2 the job failed, 3 aborted by a safety guard, 4 nothing to do`)
+	var (
+		inf  config.Influxdb1Info
+		job  config.OldSeriesInfo
+		ms   stringList
+		tags string
+		hw   string
+		cw   string
+	)
+	addServerFlags(fs, &inf, "http://localhost:8086")
+	fs.Var((*stringList)(&job.Databases), "db", "database to clean (repeatable, default all)")
+	fs.StringVar(&job.Rp, "rp", "", "retention policy")
+	fs.Var(&ms, "measurement", "measurement to clean (repeatable)")
+	fs.StringVar(&job.Measurement_regex, "measurement-regex", "", "regular expression matching the measurements to clean")
+	fs.StringVar(&job.Field, "field", "", "field with data to detect current series (default discovered, * for any)")
+	fs.StringVar(&tags, "tags", "", "one or two comma separated tags identifying series")
+	fs.StringVar(&job.Filter, "filter", "", "InfluxQL expression narrowing discovery queries, not drops")
+	fs.StringVar(&hw, "history", "", "history window as begin,end (default any time)")
+	fs.StringVar(&cw, "current", "", "current window as begin,end, like 72h,1m")
+	job.Drop_from_all = new(bool)
+	fs.BoolVar(job.Drop_from_all, "drop-from-all", false, "drop series from all measurements of the database")
+	var (
+		dryrun = fs.Bool("dryrun", true, "dry run does not drop any series")
+		yes    = fs.Bool("yes", false, "confirm dropping series when dry run is disabled")
+		auditf = fs.String("audit-log", "", "audit log file where drop statements are recorded")
+		backup = fs.String("backup-dir", "", "directory where series are saved before dropping them")
+	)
+	var logopts = addLogFlags(fs, true, "display queries and results")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	job.Name = "oldseries"
+	switch len(ms) {
+	case 0:
+	case 1:
+		job.Measurement = ms[0]
+	default:
+		job.Measurements = ms
+	}
+	job.Tags = splitList(tags)
+	job.History_window = splitList(hw)
+	job.Current_window = splitList(cw)
+	inf.Oldseries = []config.OldSeriesInfo{job}
+
+	var cfg = config.NewInfluxCleanConfig()
+	cfg.Audit_log = *auditf
+	cfg.Backup_dir = *backup
+	cfg.Influxdb1 = []config.Influxdb1Info{inf}
+	if err := cfg.Prepare(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitConfig
+	}
+	return execJobs(cfg, logopts, *dryrun, *yes)
+}
+
+// splitList splits a comma separated flag value, returning nil if empty
+func splitList(s string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+	var list = strings.Split(s, ",")
+	for i := range list {
+		list[i] = strings.TrimSpace(list[i])
+	}
+	return list
+}
@@ -0,0 +1,90 @@
+// influxclean main package provides cli and starts DB cleaning jobs
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/tesibelda/influxclean/config"
+	"github.com/tesibelda/influxclean/internal/redact"
+	"github.com/tesibelda/influxclean/jobs"
+)
+
+// runRestore writes back to a server the series saved before drops
+func runRestore(args []string) int {
+	var fs = newFlagSet("restore", descRestore+`
+
+Writes back the points saved in backup files, which run, apply and oldseries
+create in --backup-dir (backup_dir in config) before dropping series. Points
+are written to the database and retention policy they were saved from, which
+must exist. Files exported by influx_inspect export are read too. The server
+is taken from --url or, if not given, from the configuration server named by
+--server. It runs in dry run mode, only reading the files, unless
+--dryrun=false and confirmed. Example:
+
+  influxclean restore --url http://localhost:8086 --dryrun=false \
+    /var/backups/influxclean/influxclean-20240101T000000Z.lp
+
+Exit codes: 0 points restored or read, 1 usage, configuration, read or write
+error, 3 writing not confirmed`)
+	var (
+		cfgopts = addConfigFlags(fs)
+		inf     config.Influxdb1Info
+		server  string
+	)
+	addServerFlags(fs, &inf, "")
+	fs.StringVar(&server, "server", "", "name or url of the configuration server to restore to")
+	var dryrun = fs.Bool("dryrun", true, "only read the backup files without writing points")
+	var yes = fs.Bool("yes", false, "confirm writing points when dry run is disabled")
+	var logopts = addLogFlags(fs, false, "display queries and results")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	var files = fs.Args()
+	if len(files) == 0 {
+		fmt.Fprintln(os.Stderr, "No backup files given")
+		return exitConfig
+	}
+	inf, cfg, err := inspectServer(cfgopts, inf, server)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitConfig
+	}
+
+	lg, err := logopts.logger(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitConfig
+	}
+	defer lg.Close()
+	if !*dryrun && !*yes && !confirmRestore(redact.URL(inf.Url), files) {
+		return exitAborted
+	}
+	if _, err = jobs.Restore(inf, files, lg, *dryrun); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitConfig
+	}
+	return exitOK
+}
+
+// confirmRestore asks the user to confirm writing the points in files to a
+// server and reports whether they typed yes. Non-interactive sessions need --yes
+func confirmRestore(server string, files []string) bool {
+	if !isTerminal(os.Stdin) {
+		fmt.Fprintln(os.Stderr, "Refusing to write points without --yes in a non-interactive session")
+		return false
+	}
+	fmt.Fprintf(os.Stderr, "restore will write the points in %d backup files to %s.\nType yes to write, anything else to abort: ",
+		len(files),
+		server,
+	)
+	var answer, _ = bufio.NewReader(os.Stdin).ReadString('\n')
+	return strings.TrimSpace(answer) == "yes"
+}
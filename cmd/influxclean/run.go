@@ -0,0 +1,149 @@
+// influxclean main package provides cli and starts DB cleaning jobs
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/tesibelda/influxclean/audit"
+	"github.com/tesibelda/influxclean/backup"
+	"github.com/tesibelda/influxclean/config"
+	"github.com/tesibelda/influxclean/jobs"
+	"github.com/tesibelda/influxclean/log"
+)
+
+// runRun runs jobs in dry run mode unless disabled and confirmed
+func runRun(args []string) int {
+	var fs = newFlagSet("run", descRun+`
+
+Exit codes: 0 series dropped or found to drop, 1 configuration error,
+2 some job failed, 3 aborted by a safety guard, 4 nothing to do`)
+	var dryrun = fs.Bool("dryrun", true, "dry run does not drop any series")
+	return runJobs(fs, args, dryrun)
+}
+
+// runPlan runs jobs in dry run mode
+func runPlan(args []string) int {
+	var fs = newFlagSet("plan", descPlan+`
+
+Exit codes: 0 series found to drop, 1 configuration error, 2 some job
+failed, 3 aborted by a safety guard, 4 nothing to do`)
+	var dryrun = true
+	return runJobs(fs, args, &dryrun)
+}
+
+// runApply runs jobs with dry run disabled after confirmation
+func runApply(args []string) int {
+	var fs = newFlagSet("apply", descApply+`
+
+Exit codes: 0 series dropped, 1 configuration error, 2 some job failed,
+3 aborted by a safety guard, 4 nothing to do`)
+	var dryrun = false
+	return runJobs(fs, args, &dryrun)
+}
+
+// runJobs parses the common flags of run, plan and apply and runs the jobs
+func runJobs(fs *flag.FlagSet, args []string, dryrun *bool) int {
+	var (
+		cfgopts = addConfigFlags(fs)
+		selopts = addSelectFlags(fs)
+		logopts = addLogFlags(fs, true, "display queries and results")
+		yes     = fs.Bool("yes", false, "confirm dropping series when dry run is disabled")
+		auditf  = fs.String("audit-log", "", "audit log file where drop statements are recorded (default audit_log in config)")
+		backupd = fs.String("backup-dir", "", "directory where series are saved before dropping them (default backup_dir in config)")
+	)
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	var cfg, err = cfgopts.load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitConfig
+	}
+	if err = selopts.apply(cfg); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitConfig
+	}
+	if len(*auditf) > 0 {
+		cfg.Audit_log = *auditf
+	}
+	if len(*backupd) > 0 {
+		cfg.Backup_dir = *backupd
+	}
+	return execJobs(cfg, logopts, *dryrun, *yes)
+}
+
+// execJobs runs the jobs of a parsed configuration, asking for confirmation
+// before dropping series unless dry run is enabled or yes is set, and recording
+// drop statements in the config audit log if set. With dry run disabled and a
+// backup directory set, the points of series are saved to a file before drops
+func execJobs(cfg *config.InfluxCleanConfig, logopts *logOptions, dryrun, yes bool) int {
+	var l, err = logopts.logger(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitConfig
+	}
+	defer l.Close()
+	if len(cfg.Audit_log) > 0 {
+		var a *audit.Log
+		if a, err = audit.Open(cfg.Audit_log); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitConfig
+		}
+		defer a.Close()
+		jobs.SetAuditLog(a)
+	}
+	if !dryrun && len(cfg.Backup_dir) > 0 {
+		var b *backup.Writer
+		if b, err = backup.Create(cfg.Backup_dir); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitConfig
+		}
+		defer closeBackup(b, l)
+		jobs.SetBackup(b)
+	}
+	if !dryrun && !yes {
+		if !isTerminal(os.Stdin) {
+			fmt.Fprintln(os.Stderr, "Refusing to drop series without --yes in a non-interactive session")
+			return exitAborted
+		}
+		var tc = &terminalConfirmer{in: bufio.NewReader(os.Stdin), out: os.Stderr}
+		jobs.SetConfirmer(tc.confirm)
+	}
+
+	// run cleanup jobs
+	var rep, _ = jobs.RunJobs(cfg, l, dryrun)
+	return reportExitCode(rep)
+}
+
+// reportExitCode returns the exit code for the report of a run: failures take
+// precedence over aborted drops, which take precedence over nothing to do
+func reportExitCode(rep *jobs.Report) int {
+	switch {
+	case rep.Failed():
+		return exitJobs
+	case rep.Has(jobs.KindAborted):
+		return exitAborted
+	case rep.Candidates == 0:
+		return exitNothing
+	}
+	return exitOK
+}
+
+// closeBackup closes the backup file and logs where the saved series are
+func closeBackup(b *backup.Writer, l *log.Logger) {
+	if err := b.Close(); err != nil {
+		l.Errorf("Could not close backup file %s: %v", b.Path(), err)
+		return
+	}
+	if n := b.Lines(); n > 0 {
+		l.Infof("Saved %d points of dropped series to %s, use influxclean restore to write them back", n, b.Path())
+	}
+}
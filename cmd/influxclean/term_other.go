@@ -0,0 +1,18 @@
+// influxclean main package provides cli and starts DB cleaning jobs
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+//go:build !linux && !windows
+
+package main
+
+import (
+	"os"
+)
+
+// isTerminal reports whether the file is a character device like a terminal
+func isTerminal(f *os.File) bool {
+	var fi, err = f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,78 @@
+// influxclean main package provides cli and starts DB cleaning jobs
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/tesibelda/influxclean/config"
+	"github.com/tesibelda/influxclean/internal/redact"
+	"github.com/tesibelda/influxclean/jobs"
+	"github.com/tesibelda/influxclean/log"
+)
+
+// runValidate validates a configuration file reporting all the problems found
+// and explains its jobs
+func runValidate(args []string) int {
+	var (
+		cfgfiles []string
+		err      error
+	)
+
+	var fs = newFlagSet("validate", descValidate+`
+
+Exit codes: 0 configuration is valid, 1 problems were found`)
+	var cfgopts = addConfigFlags(fs)
+	var online = fs.Bool("online", false, "connect to servers to check databases, retention policies, measurements, fields and tags")
+	var logopts = addLogFlags(fs, false, "display queries and results of online checks")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	if cfgfiles, err = cfgopts.files(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitConfig
+	}
+	var cfg = config.NewInfluxCleanConfig()
+	var problems = cfg.DiagnoseFiles(cfgfiles, cfgopts.cfgfmt)
+	var desc = strings.Join(cfgfiles, ", ")
+	if err = log.CheckOptions(logopts.options(cfg)); err != nil {
+		problems = append(problems, config.Problem{File: desc, Err: err})
+	}
+	if *online && len(problems) == 0 {
+		var lg *log.Logger
+		if lg, err = logopts.logger(cfg); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitConfig
+		}
+		defer lg.Close()
+		problems = jobs.ValidateOnline(cfg, lg)
+	}
+	for _, p := range problems {
+		fmt.Fprintln(os.Stderr, p)
+	}
+	if len(problems) > 0 {
+		fmt.Fprintf(os.Stderr, "Configuration %s has %d problems\n", desc, len(problems))
+		return exitConfig
+	}
+	fmt.Printf("Configuration %s is valid\n", desc)
+	printJobs(cfg)
+	return exitOK
+}
+
+// printJobs prints the jobs of a configuration with their windows explained
+func printJobs(cfg *config.InfluxCleanConfig) {
+	for _, inf := range cfg.Influxdb1 {
+		fmt.Printf("influxdb1 %s\n", redact.URL(inf.Url))
+		for _, job := range inf.Oldseries {
+			fmt.Printf("  oldseries job %s (%s)\n", job.Name, job.Source)
+			fmt.Printf("    history window: %s\n", config.DescribeWindow(job.History_window))
+			fmt.Printf("    current window: %s\n", config.DescribeWindow(job.Current_window))
+		}
+	}
+}
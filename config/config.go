@@ -9,66 +9,157 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"time"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
 
-	"github.com/pelletier/go-toml"
+	"github.com/tesibelda/influxclean/internal/pattern"
+	"github.com/tesibelda/influxclean/internal/redact"
+	"github.com/tesibelda/influxclean/internal/sliceplus"
+	"github.com/tesibelda/influxclean/internal/timewindow"
 )
 
 type InfluxCleanConfig struct {
-	Name      string
-	Influxdb1 []Influxdb1Info
+	Name       string
+	Include    []string
+	Audit_log  string
+	Backup_dir string
+	Log        LogInfo
+	Templates  map[string]OldSeriesInfo
+	Influxdb1  []Influxdb1Info
 }
 
 type Influxdb1Info struct {
+	Name                 string
 	Url                  string
 	Env_user             string
 	Env_password         string
 	User                 string
 	Password             string
-	Insecure_skip_verify bool
+	Password_file        string
+	Token                string
+	Token_file           string
+	Insecure_skip_verify *bool
+	Protect              map[string][]string
+	Defaults             OldSeriesInfo
 	Oldseries            []OldSeriesInfo
+	Source               Source `toml:"-" yaml:"-" json:"-"`
 }
 
 type OldSeriesInfo struct {
-	Name           string
-	Databases      []string
-	Rp             string
-	Measurement    string
-	Field          string
-	Filter         string
-	Tags           []string
-	Drop_from_all  bool
-	Sleep_duration string
-	History_window []string
-	Current_window []string
+	Name              string
+	Extends           string
+	Preset            string
+	Labels            map[string]string
+	Enabled           *bool
+	Dryrun            string
+	Databases         []string
+	Rp                string
+	Measurement       string
+	Measurements      []string
+	Measurement_regex string
+	Field             string
+	Fields            map[string]string
+	Filter            string
+	Tags              []string
+	Drop_from_all     *bool
+	Sleep_duration    string
+	History_window    []string
+	Current_window    []string
+	Protect           map[string][]string
+	Include           []FilterInfo
+	Exclude           []FilterInfo
+	Source            Source `toml:"-" yaml:"-" json:"-"`
+}
+
+// LogInfo holds the log settings, overridden by command line flags
+type LogInfo struct {
+	Format      string
+	Level       string
+	Output      string
+	File        string
+	Max_size    int64
+	Max_backups int
+	Syslog      string
+	Facility    string
+	Tag         string
+}
+
+type FilterInfo struct {
+	Tag      string
+	Operator string
+	Value    string
 }
 
 var ErrorString_ParseFailed = "Configuration parse failed"
 
+// Job dry run modes, inherit uses the dry run mode given in the command line
+const (
+	DryrunInherit = "inherit"
+	DryrunAlways  = "always"
+)
+
+// Configuration file formats
+const (
+	FormatTOML = "toml"
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+)
+
 func NewInfluxCleanConfig() *InfluxCleanConfig {
 	var c = &InfluxCleanConfig{}
 	return c
 }
 
-// ReadFile reads a config reader, expands env variables and parse config
+// ReadFile reads a TOML config reader, expands env variables and parse config
 func (c *InfluxCleanConfig) ReadFile(f io.Reader) error {
-	var err error
+	return c.ReadFormat(f, FormatTOML)
+}
 
-	if err = toml.NewDecoder(f).Decode(c); err != nil {
-		return err
+// ReadFormat reads a config reader in the given format (toml, yaml or json),
+// expands env variables and parse config. Include directives are not processed,
+// use LoadFiles for that
+func (c *InfluxCleanConfig) ReadFormat(f io.Reader, format string) error {
+	var fc, problems = decodeReader(f, "", format, false)
+	if len(problems) > 0 {
+		return problems[0].Err
 	}
+	*c = *fc
+	return c.Prepare()
+}
+
+// Prepare sets default values and parses a config, as done when reading it
+// from files. Use it for configs built in memory
+func (c *InfluxCleanConfig) Prepare() error {
 	c.defaultOldSeriesConfig()
 	return c.parseConfig()
 }
 
+// FormatFromFile returns the configuration format of a file by its extension,
+// which is toml unless the extension is .yaml, .yml or .json
+func FormatFromFile(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".json":
+		return FormatJSON
+	}
+	return FormatTOML
+}
+
 // defaultOldSeriesConfig sets default values if not provided
 func (c *InfluxCleanConfig) defaultOldSeriesConfig() {
 	for i := range c.Influxdb1 {
 		for j := range c.Influxdb1[i].Oldseries {
 			var job = &c.Influxdb1[i].Oldseries[j]
+			job.inherit(c.Templates, c.Influxdb1[i].Defaults)
 			job.Sleep_duration = defaultDuration(job.Sleep_duration)
 			job.History_window = defaultWindowDuration(job.History_window)
 			job.Current_window = defaultWindowDuration(job.Current_window)
+			job.Protect = mergeProtect(tagsProtect(c.Influxdb1[i].Protect, job.Tags), job.Protect)
+			job.Include = defaultFilterOperator(job.Include)
+			job.Exclude = defaultFilterOperator(job.Exclude)
 		}
 	}
 }
@@ -77,12 +168,18 @@ func (c *InfluxCleanConfig) defaultOldSeriesConfig() {
 func (c *InfluxCleanConfig) parseConfig() error {
 	var err error
 
-	for i, inf := range c.Influxdb1 {
-		if len(inf.Env_user) > 0 {
-			c.Influxdb1[i].User = os.Getenv(inf.Env_user)
+	if errs := c.readEnv(); len(errs) > 0 {
+		return errs[0]
+	}
+	if errs := c.templateProblems(); len(errs) > 0 {
+		return errs[0]
+	}
+	for _, inf := range c.Influxdb1 {
+		if err = parseProtect(inf.Protect, "Server"); err != nil {
+			return err
 		}
-		if len(inf.Env_password) > 0 {
-			c.Influxdb1[i].Password = os.Getenv(inf.Env_password)
+		if errs := duplicateJobs(inf); len(errs) > 0 {
+			return errs[0]
 		}
 		if err = parseOldSeriesConfig(inf, c); err != nil {
 			return err
@@ -91,58 +188,240 @@ func (c *InfluxCleanConfig) parseConfig() error {
 	return err
 }
 
+// readEnv expands environment variable references and sets credentials from
+// the environment variables and secret files given in the config
+func (c *InfluxCleanConfig) readEnv() []error {
+	var errs []error
+	var err error
+
+	c.expandEnv()
+	for i, inf := range c.Influxdb1 {
+		var srv = &c.Influxdb1[i]
+		if len(inf.Token_file) > 0 {
+			if srv.Token, err = readSecretFile(inf.Token_file, "Token_file"); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if len(inf.Password_file) > 0 {
+			if srv.Password, err = readSecretFile(inf.Password_file, "Password_file"); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if len(inf.Env_user) > 0 {
+			srv.User = os.Getenv(inf.Env_user)
+		}
+		if len(inf.Env_password) > 0 {
+			srv.Password = os.Getenv(inf.Env_password)
+		}
+		if len(srv.Password) == 0 {
+			srv.Password = srv.Token
+		}
+	}
+	return errs
+}
+
+// IsEnabled reports whether the job is enabled, which is the default
+func (job OldSeriesInfo) IsEnabled() bool {
+	return job.Enabled == nil || *job.Enabled
+}
+
+// DropsFromAll reports whether the job drops series from all measurements,
+// which is not the default
+func (job OldSeriesInfo) DropsFromAll() bool {
+	return job.Drop_from_all != nil && *job.Drop_from_all
+}
+
+// SkipsVerify reports whether TLS certificate verification is skipped, which
+// is not the default
+func (inf Influxdb1Info) SkipsVerify() bool {
+	return inf.Insecure_skip_verify != nil && *inf.Insecure_skip_verify
+}
+
 // parseOldSeriesConfig parses an OldSeries job config
 func parseOldSeriesConfig(inf Influxdb1Info, c *InfluxCleanConfig) error {
-	var err error
 	for _, job := range inf.Oldseries {
-		if len(job.Tags) == 0 || len(job.Tags) > 2 {
-			return fmt.Errorf("%s. Only one or two tags clean jobs are possible",
+		if errs := oldSeriesProblems(job); len(errs) > 0 {
+			return errs[0]
+		}
+	}
+	return nil
+}
+
+// duplicateJobs returns an error for each job name repeated in a server
+func duplicateJobs(inf Influxdb1Info) []error {
+	var errs []error
+	var seen = make(map[string]Source)
+	for _, job := range inf.Oldseries {
+		if src, ok := seen[job.Name]; ok {
+			errs = append(errs, fmt.Errorf("%s. Duplicate job name %s for %s in %s and %s",
 				ErrorString_ParseFailed,
-			)
+				job.Name,
+				redact.URL(inf.Url),
+				src,
+				job.Source,
+			))
+			continue
+		}
+		seen[job.Name] = job.Source
+	}
+	return errs
+}
+
+// oldSeriesProblems returns all the problems found in an OldSeries job config
+func oldSeriesProblems(job OldSeriesInfo) []error {
+	var errs []error
+	var add = func(err error) {
+		if err != nil {
+			errs = append(errs, err)
 		}
-		if _, err = time.ParseDuration(job.Sleep_duration); err != nil {
-			return fmt.Errorf("%s. Sleep_duration field could not be parsed: %v",
+	}
+
+	add(presetProblem(job))
+	if len(job.Tags) == 0 || len(job.Tags) > 2 {
+		add(fmt.Errorf("%s. Only one or two tags clean jobs are possible",
+			ErrorString_ParseFailed,
+		))
+	}
+	if len(job.Measurement) == 0 && len(job.Measurements) == 0 &&
+		len(job.Measurement_regex) == 0 {
+		add(fmt.Errorf("%s. Job %s needs measurement, measurements or measurement_regex",
+			ErrorString_ParseFailed,
+			job.Name,
+		))
+	}
+	if _, err := regexp.Compile(job.Measurement_regex); err != nil {
+		add(fmt.Errorf("%s. Measurement_regex field could not be parsed: %v",
+			ErrorString_ParseFailed,
+			err,
+		))
+	}
+	if _, err := timewindow.ParseDuration(job.Sleep_duration); err != nil {
+		add(fmt.Errorf("%s. Sleep_duration field could not be parsed: %v",
+			ErrorString_ParseFailed,
+			err,
+		))
+	}
+	switch job.Dryrun {
+	case "", DryrunInherit, DryrunAlways:
+	default:
+		add(fmt.Errorf("%s. Dryrun %q is not one of %s or %s",
+			ErrorString_ParseFailed,
+			job.Dryrun,
+			DryrunInherit,
+			DryrunAlways,
+		))
+	}
+	add(parseWindows(job))
+	add(parseProtect(job.Protect, "Job "+job.Name))
+	add(protectTagsProblem(job))
+	add(parseFilters(job.Include, "Include"))
+	add(parseFilters(job.Exclude, "Exclude"))
+	return errs
+}
+
+// parseProtect parses a protect list of exact, glob or regex values per tag key
+func parseProtect(prot map[string][]string, desc string) error {
+	for tag, vals := range prot {
+		if _, err := pattern.CompileList(vals); err != nil {
+			return fmt.Errorf("%s. %s protect entry for tag %s could not be parsed: %v",
 				ErrorString_ParseFailed,
+				desc,
+				tag,
 				err,
 			)
 		}
-		if err = parseWindow(job.History_window, "History"); err != nil {
-			return err
-		}
-		if err = parseWindow(job.Current_window, "Current"); err != nil {
-			return err
-		}
 	}
-	return err
+	return nil
 }
 
-// parseWindow parses a relative time window config entry
-func parseWindow(w []string, desc string) error {
-	var t, tf time.Duration
-	var err error
-	if len(w) > 2 {
-		return fmt.Errorf("%s. %s and current window should include two durations",
-			ErrorString_ParseFailed,
-			desc,
-		)
+// protectTagsProblem returns an error if the job protect lists have tag keys
+// which are not job tags. Candidates are only matched by the job tags, so those
+// entries would protect nothing. Server entries for other tags are left out when
+// merged, so only the job own entries are checked
+func protectTagsProblem(job OldSeriesInfo) error {
+	var keys []string
+	for tag := range job.Protect {
+		if !sliceplus.Contains(job.Tags, tag) {
+			keys = append(keys, tag)
+		}
+	}
+	if len(keys) == 0 || len(job.Tags) == 0 {
+		return nil
 	}
-	for k := 0; k < len(w); k++ {
-		if _, err = time.ParseDuration(w[k]); err != nil {
-			return fmt.Errorf("%s. %s time window could not be parsed: %v",
+	sort.Strings(keys)
+	return fmt.Errorf("%s. Job %s protect entries for %s would protect nothing as they are not job tags (%s), move them to jobs with those tags",
+		ErrorString_ParseFailed,
+		job.Name,
+		strings.Join(keys, ", "),
+		strings.Join(job.Tags, ", "),
+	)
+}
+
+// parseFilters parses structured include or exclude filter entries
+func parseFilters(fs []FilterInfo, desc string) error {
+	for _, f := range fs {
+		if len(f.Tag) == 0 {
+			return fmt.Errorf("%s. %s filter entry without tag",
 				ErrorString_ParseFailed,
 				desc,
-				err,
 			)
 		}
-		if k == 1 && t.Seconds() > tf.Seconds() {
-			return fmt.Errorf("%s. %s relative times are not from older to newer",
+		switch f.Operator {
+		case "=", "!=":
+		case "=~", "!~":
+			if _, err := regexp.Compile(f.Value); err != nil {
+				return fmt.Errorf("%s. %s filter regex for tag %s could not be parsed: %v",
+					ErrorString_ParseFailed,
+					desc,
+					f.Tag,
+					err,
+				)
+			}
+		default:
+			return fmt.Errorf("%s. %s filter operator %q for tag %s is not one of =, !=, =~, !~",
 				ErrorString_ParseFailed,
 				desc,
+				f.Operator,
+				f.Tag,
 			)
 		}
-		tf = t
 	}
-	return err
+	return nil
+}
+
+// tagsProtect returns the protect lists of prot for the given tags
+func tagsProtect(prot map[string][]string, tags []string) map[string][]string {
+	var tp = make(map[string][]string)
+	for tag, vals := range prot {
+		if sliceplus.Contains(tags, tag) {
+			tp[tag] = vals
+		}
+	}
+	return tp
+}
+
+// mergeProtect returns the server protect lists merged with the job ones
+func mergeProtect(server, job map[string][]string) map[string][]string {
+	if len(server) == 0 {
+		return job
+	}
+	var prot = make(map[string][]string, len(server)+len(job))
+	for tag, vals := range server {
+		prot[tag] = append(prot[tag], vals...)
+	}
+	for tag, vals := range job {
+		prot[tag] = append(prot[tag], vals...)
+	}
+	return prot
+}
+
+func defaultFilterOperator(fs []FilterInfo) []FilterInfo {
+	for k := range fs {
+		if len(fs[k].Operator) == 0 {
+			fs[k].Operator = "="
+		}
+	}
+	return fs
 }
 
 func defaultDuration(s string) string {
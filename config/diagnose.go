@@ -0,0 +1,145 @@
+// influxclean config package provides access to configuration files
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pelletier/go-toml"
+
+	"github.com/tesibelda/influxclean/internal/redact"
+)
+
+// Problem is a configuration problem with the location where it was found
+type Problem struct {
+	File   string
+	Line   int
+	Server string
+	Job    string
+	Err    error
+}
+
+// String returns the problem prefixed by its location
+func (p Problem) String() string {
+	var loc = p.File
+	if p.Line > 0 {
+		loc = fmt.Sprintf("%s:%d", loc, p.Line)
+	}
+	var ctx string
+	switch {
+	case len(p.Job) > 0:
+		ctx = fmt.Sprintf(" job %s:", p.Job)
+	case len(p.Server) > 0:
+		ctx = fmt.Sprintf(" influxdb1 %s:", p.Server)
+	}
+	var msg = strings.TrimPrefix(p.Err.Error(), ErrorString_ParseFailed+". ")
+	return fmt.Sprintf("%s:%s %s", loc, ctx, msg)
+}
+
+// DiagnoseFiles loads configuration files like LoadFiles does but, instead of
+// stopping at the first error, returns all the problems found including unknown
+// keys. The config is left loaded with defaults for further checks
+func (c *InfluxCleanConfig) DiagnoseFiles(paths []string, format string) []Problem {
+	var problems = c.load(paths, format, true, map[string]bool{})
+	c.defaultOldSeriesConfig()
+	for _, err := range c.readEnv() {
+		problems = append(problems, Problem{Err: err})
+	}
+	for _, err := range c.templateProblems() {
+		problems = append(problems, Problem{Err: err})
+	}
+	for _, inf := range c.Influxdb1 {
+		var sp = Problem{File: inf.Source.File, Line: inf.Source.Line, Server: redact.URL(inf.Url)}
+		if sp.Err = parseProtect(inf.Protect, "Server"); sp.Err != nil {
+			problems = append(problems, sp)
+		}
+		for _, sp.Err = range duplicateJobs(inf) {
+			problems = append(problems, sp)
+		}
+		for _, job := range inf.Oldseries {
+			var jp = Problem{
+				File:   job.Source.File,
+				Line:   job.Source.Line,
+				Server: redact.URL(inf.Url),
+				Job:    job.Name,
+			}
+			for _, jp.Err = range oldSeriesProblems(job) {
+				problems = append(problems, jp)
+			}
+		}
+	}
+	return problems
+}
+
+// unknownKeys returns a problem for each key in tree without a matching field in
+// struct type t, recursing into tables and arrays of tables
+func unknownKeys(tree *toml.Tree, t reflect.Type, file, path string) []Problem {
+	var problems []Problem
+	var keys = tree.Keys()
+
+	sort.Strings(keys)
+	for _, key := range keys {
+		field, ok := fieldForKey(t, key)
+		if !ok {
+			problems = append(problems, Problem{
+				File: file,
+				Line: tree.GetPosition(key).Line,
+				Err:  fmt.Errorf("unknown key %s%s", path, key),
+			})
+			continue
+		}
+		var ft = field.Type
+		var isMap = ft.Kind() == reflect.Map
+		if ft.Kind() == reflect.Slice || isMap {
+			ft = ft.Elem()
+		}
+		if ft.Kind() != reflect.Struct {
+			continue
+		}
+		switch sub := tree.Get(key).(type) {
+		case *toml.Tree:
+			if !isMap {
+				problems = append(problems, unknownKeys(sub, ft, file, path+key+".")...)
+				continue
+			}
+			for _, name := range sub.Keys() {
+				if st, ok := sub.Get(name).(*toml.Tree); ok {
+					problems = append(problems, unknownKeys(st, ft, file, path+key+"."+name+".")...)
+				}
+			}
+		case []*toml.Tree:
+			for _, st := range sub {
+				problems = append(problems, unknownKeys(st, ft, file, path+key+".")...)
+			}
+		}
+	}
+	return problems
+}
+
+// fieldForKey returns the struct field a TOML key is decoded into, matching
+// names the same way the TOML decoder does
+func fieldForKey(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		var f = t.Field(i)
+		if f.Tag.Get("toml") == "-" {
+			continue
+		}
+		for _, name := range []string{
+			f.Name,
+			strings.ToLower(f.Name),
+			strings.ToTitle(f.Name),
+			strings.ToLower(f.Name[:1]) + f.Name[1:],
+		} {
+			if key == name {
+				return f, true
+			}
+		}
+	}
+	return reflect.StructField{}, false
+}
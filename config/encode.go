@@ -0,0 +1,110 @@
+// influxclean config package provides access to configuration files
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/pelletier/go-toml"
+	"gopkg.in/yaml.v3"
+)
+
+// MergeFiles reads and merges configuration files like LoadFiles does, without
+// applying defaults, templates or validation
+func MergeFiles(paths []string, format string) (*InfluxCleanConfig, error) {
+	var c = NewInfluxCleanConfig()
+	if problems := c.load(paths, format, false, map[string]bool{}); len(problems) > 0 {
+		return nil, fmt.Errorf("Could not load config in file %s: %w",
+			problems[0].File,
+			problems[0].Err,
+		)
+	}
+	return c, nil
+}
+
+// Encode writes the config in the given format (toml, yaml or json) using the
+// same keys as configuration files and omitting settings not set
+func (c *InfluxCleanConfig) Encode(w io.Writer, format string) error {
+	var doc map[string]interface{}
+
+	// round trip through yaml to get lower case keys as in configuration files
+	b, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err = yaml.Unmarshal(b, &doc); err != nil {
+		return err
+	}
+	doc, _ = pruneEmpty(doc).(map[string]interface{})
+	if doc == nil {
+		doc = map[string]interface{}{}
+	}
+
+	switch format {
+	case FormatTOML:
+		tree, err := toml.TreeFromMap(doc)
+		if err != nil {
+			return err
+		}
+		_, err = tree.WriteTo(w)
+		return err
+	case FormatYAML:
+		var e = yaml.NewEncoder(w)
+		e.SetIndent(2)
+		if err = e.Encode(doc); err != nil {
+			return err
+		}
+		return e.Close()
+	case FormatJSON:
+		var e = json.NewEncoder(w)
+		e.SetIndent("", "  ")
+		return e.Encode(doc)
+	}
+	return fmt.Errorf("unknown configuration format %s", format)
+}
+
+// pruneEmpty removes nil values, empty strings, zero integers and empty
+// collections from a decoded document
+func pruneEmpty(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, e := range t {
+			if e = pruneEmpty(e); e == nil {
+				delete(t, k)
+				continue
+			}
+			t[k] = e
+		}
+		if len(t) == 0 {
+			return nil
+		}
+		return t
+	case []interface{}:
+		var list []interface{}
+		for _, e := range t {
+			if e = pruneEmpty(e); e != nil {
+				list = append(list, e)
+			}
+		}
+		if len(list) == 0 {
+			return nil
+		}
+		return list
+	case string:
+		if len(t) == 0 {
+			return nil
+		}
+	case int:
+		if t == 0 {
+			return nil
+		}
+	case nil:
+		return nil
+	}
+	return v
+}
@@ -0,0 +1,268 @@
+// influxclean config package provides access to configuration files
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+
+	"github.com/pelletier/go-toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Source is the file and line where a server or job is defined
+type Source struct {
+	File string
+	Line int
+}
+
+// String returns the source as file:line
+func (s Source) String() string {
+	if s.Line > 0 {
+		return fmt.Sprintf("%s:%d", s.File, s.Line)
+	}
+	return s.File
+}
+
+// LoadFiles reads the given configuration files and the files they include,
+// merging them in order, and parses the resulting config. Files are read in the
+// given format or the one matching their extension if empty
+func LoadFiles(paths []string, format string) (*InfluxCleanConfig, error) {
+	var c = NewInfluxCleanConfig()
+	if problems := c.load(paths, format, false, map[string]bool{}); len(problems) > 0 {
+		return nil, fmt.Errorf("Could not load config in file %s: %w",
+			problems[0].File,
+			problems[0].Err,
+		)
+	}
+	return c, c.Prepare()
+}
+
+// DirFiles returns the sorted list of toml, yaml and json files in a directory
+func DirFiles(dir string) ([]string, error) {
+	var files []string
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		switch filepath.Ext(e.Name()) {
+		case ".toml", ".yaml", ".yml", ".json":
+			if !e.IsDir() {
+				files = append(files, filepath.Join(dir, e.Name()))
+			}
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// load reads and merges the given files and the files they include, skipping
+// those already seen, and returns the problems found
+func (c *InfluxCleanConfig) load(paths []string, format string, strict bool, seen map[string]bool) []Problem {
+	var problems []Problem
+
+	for _, path := range paths {
+		if abs, err := filepath.Abs(path); err == nil {
+			if seen[abs] {
+				continue
+			}
+			seen[abs] = true
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			problems = append(problems, Problem{File: path, Err: err})
+			continue
+		}
+		var ff = format
+		if len(ff) == 0 {
+			ff = FormatFromFile(path)
+		}
+		fc, fproblems := decodeReader(f, path, ff, strict)
+		f.Close()
+		problems = append(problems, fproblems...)
+		if fc == nil {
+			continue
+		}
+		c.merge(fc)
+		for _, inc := range fc.Include {
+			if !filepath.IsAbs(inc) {
+				inc = filepath.Join(filepath.Dir(path), inc)
+			}
+			matches, err := filepath.Glob(inc)
+			if err != nil {
+				problems = append(problems, Problem{File: path, Err: err})
+				continue
+			}
+			problems = append(problems, c.load(matches, "", strict, seen)...)
+		}
+	}
+	return problems
+}
+
+// merge merges another config into c. Servers are merged by name, or url if
+// they have no name, appending their jobs and filling unset settings
+func (c *InfluxCleanConfig) merge(o *InfluxCleanConfig) {
+	if len(c.Name) == 0 {
+		c.Name = o.Name
+	}
+	if len(c.Audit_log) == 0 {
+		c.Audit_log = o.Audit_log
+	}
+	if len(c.Backup_dir) == 0 {
+		c.Backup_dir = o.Backup_dir
+	}
+	c.Log.merge(o.Log)
+	for name, t := range o.Templates {
+		if c.Templates == nil {
+			c.Templates = make(map[string]OldSeriesInfo)
+		}
+		if _, ok := c.Templates[name]; !ok {
+			c.Templates[name] = t
+		}
+	}
+	for _, inf := range o.Influxdb1 {
+		var found bool
+		for i := range c.Influxdb1 {
+			if c.Influxdb1[i].sameServer(inf) {
+				c.Influxdb1[i].merge(inf)
+				found = true
+				break
+			}
+		}
+		if !found {
+			c.Influxdb1 = append(c.Influxdb1, inf)
+		}
+	}
+}
+
+// merge fills the log settings not set in li with the ones in o
+func (li *LogInfo) merge(o LogInfo) {
+	var lv = reflect.ValueOf(li).Elem()
+	var ov = reflect.ValueOf(o)
+	for i := 0; i < lv.NumField(); i++ {
+		if lv.Field(i).IsZero() {
+			lv.Field(i).Set(ov.Field(i))
+		}
+	}
+}
+
+// sameServer reports whether two influxdb1 entries refer to the same server
+func (inf *Influxdb1Info) sameServer(o Influxdb1Info) bool {
+	if len(inf.Name) > 0 && len(o.Name) > 0 {
+		return inf.Name == o.Name
+	}
+	return len(inf.Url) > 0 && inf.Url == o.Url
+}
+
+// merge appends the jobs of another entry for the same server and fills settings
+// not set in inf
+func (inf *Influxdb1Info) merge(o Influxdb1Info) {
+	for _, f := range []struct{ dst, src *string }{
+		{&inf.Name, &o.Name},
+		{&inf.Url, &o.Url},
+		{&inf.Env_user, &o.Env_user},
+		{&inf.Env_password, &o.Env_password},
+		{&inf.User, &o.User},
+		{&inf.Password, &o.Password},
+		{&inf.Password_file, &o.Password_file},
+		{&inf.Token, &o.Token},
+		{&inf.Token_file, &o.Token_file},
+	} {
+		if len(*f.dst) == 0 {
+			*f.dst = *f.src
+		}
+	}
+	if inf.Insecure_skip_verify == nil {
+		inf.Insecure_skip_verify = o.Insecure_skip_verify
+	}
+	inf.Protect = mergeProtect(inf.Protect, o.Protect)
+	fillUnset(&inf.Defaults, o.Defaults)
+	inf.Oldseries = append(inf.Oldseries, o.Oldseries...)
+}
+
+// decodeReader decodes a config in the given format without setting defaults,
+// recording the source of servers and jobs. If strict, unknown keys are problems
+func decodeReader(f io.Reader, file, format string, strict bool) (*InfluxCleanConfig, []Problem) {
+	var (
+		c           = NewInfluxCleanConfig()
+		serverLines []int
+		jobLines    [][]int
+		problems    []Problem
+		err         error
+	)
+
+	switch format {
+	case FormatTOML:
+		var tree *toml.Tree
+		if tree, err = toml.LoadReader(f); err != nil {
+			return nil, []Problem{{File: file, Err: err}}
+		}
+		if strict {
+			problems = unknownKeys(tree, reflect.TypeOf(*c), file, "")
+		}
+		if err = tree.Unmarshal(c); err != nil {
+			return nil, append(problems, Problem{File: file, Err: err})
+		}
+		serverLines, jobLines = treeLines(tree)
+	case FormatYAML:
+		var d = yaml.NewDecoder(f)
+		d.KnownFields(strict)
+		if err = d.Decode(c); err != nil && err != io.EOF {
+			return nil, []Problem{{File: file, Err: err}}
+		}
+	case FormatJSON:
+		var d = json.NewDecoder(f)
+		if strict {
+			d.DisallowUnknownFields()
+		}
+		if err = d.Decode(c); err != nil {
+			return nil, []Problem{{File: file, Err: err}}
+		}
+	default:
+		return nil, []Problem{{File: file, Err: fmt.Errorf("unknown configuration format %s", format)}}
+	}
+
+	for i := range c.Influxdb1 {
+		var inf = &c.Influxdb1[i]
+		inf.Source = Source{File: file}
+		if i < len(serverLines) {
+			inf.Source.Line = serverLines[i]
+		}
+		for j := range inf.Oldseries {
+			inf.Oldseries[j].Source = Source{File: file}
+			if i < len(jobLines) && j < len(jobLines[i]) {
+				inf.Oldseries[j].Source.Line = jobLines[i][j]
+			}
+		}
+	}
+	return c, problems
+}
+
+// treeLines returns the lines where each influxdb1 entry and each of its
+// oldseries jobs are defined in a TOML tree
+func treeLines(tree *toml.Tree) ([]int, [][]int) {
+	var serverLines []int
+	var jobLines [][]int
+
+	var servers, _ = tree.Get("influxdb1").([]*toml.Tree)
+	for _, st := range servers {
+		var lines []int
+		var jobs, _ = st.Get("oldseries").([]*toml.Tree)
+		for _, jt := range jobs {
+			lines = append(lines, jt.Position().Line)
+		}
+		serverLines = append(serverLines, st.Position().Line)
+		jobLines = append(jobLines, lines)
+	}
+	return serverLines, jobLines
+}
@@ -0,0 +1,88 @@
+// influxclean config package provides access to configuration files
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// presets is the catalog of oldseries settings for Telegraf input plugins,
+// with the measurements, field and tags detecting when their sources are gone
+var presets = map[string]OldSeriesInfo{
+	"telegraf-linux": {
+		Measurement: "system",
+		Field:       "uptime",
+		Tags:        []string{"host"},
+	},
+	"telegraf-windows": {
+		Measurement: "win_system",
+		Field:       "Processor_Queue_Length",
+		Tags:        []string{"host"},
+	},
+	"telegraf-docker": {
+		Measurement_regex: "^docker_container_",
+		Field:             "*",
+		Tags:              []string{"host", "container_name"},
+	},
+	"telegraf-kubernetes": {
+		Measurement: "kubernetes_pod_container",
+		Field:       "*",
+		Tags:        []string{"namespace", "pod_name"},
+	},
+	"telegraf-vsphere": {
+		Measurement: "vsphere_vm_cpu",
+		Field:       "usage_average",
+		Tags:        []string{"vmname"},
+	},
+}
+
+// Preset returns the settings of a preset in the catalog
+func Preset(name string) (OldSeriesInfo, bool) {
+	var p, ok = presets[name]
+	return p, ok
+}
+
+// PresetNames returns the sorted names of the presets in the catalog
+func PresetNames() []string {
+	var names = make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyPreset fills the settings not set in a job from its preset. The
+// measurement keys (measurement, measurements and measurement_regex) and the
+// field keys (field and fields) are overridden as a whole when any is set
+func (job *OldSeriesInfo) applyPreset() {
+	var p, ok = presets[job.Preset]
+	if !ok {
+		return
+	}
+	if len(job.Measurement) > 0 || len(job.Measurements) > 0 || len(job.Measurement_regex) > 0 {
+		p.Measurement, p.Measurements, p.Measurement_regex = "", nil, ""
+	}
+	if len(job.Field) > 0 || len(job.Fields) > 0 {
+		p.Field, p.Fields = "", nil
+	}
+	fillUnset(job, p)
+}
+
+// presetProblem returns an error if the job preset is not in the catalog
+func presetProblem(job OldSeriesInfo) error {
+	if _, ok := presets[job.Preset]; ok || len(job.Preset) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s. Job %s uses unknown preset %s, use one of %s",
+		ErrorString_ParseFailed,
+		job.Name,
+		job.Preset,
+		strings.Join(PresetNames(), ", "),
+	)
+}
@@ -0,0 +1,70 @@
+// influxclean config package provides access to configuration files
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestServerProtect(t *testing.T) {
+	var tests = []struct {
+		name string
+		job  string
+		want map[string][]string
+		err  bool
+	}{
+		{
+			name: "server entries for job tags",
+			job:  `tags = ["host"]`,
+			want: map[string][]string{"host": {"db01"}},
+		},
+		{
+			name: "server entries for other tags left out",
+			job:  `tags = ["namespace", "pod_name"]`,
+		},
+		{
+			name: "merged with job entries",
+			job: `tags = ["host"]
+    [influxdb1.oldseries.protect]
+      host = ["/^prod-/"]`,
+			want: map[string][]string{"host": {"db01", "/^prod-/"}},
+		},
+		{
+			name: "job entries for other tags rejected",
+			job: `tags = ["vmname"]
+    [influxdb1.oldseries.protect]
+      host = ["db01"]`,
+			err: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var conf = `
+[[influxdb1]]
+  url = "http://localhost:8086"
+  [influxdb1.protect]
+    host = ["db01"]
+  [[influxdb1.oldseries]]
+    name = "job"
+    measurement = "cpu"
+    current_window = ["72h", "1m"]
+    ` + tt.job + "\n"
+			var c = NewInfluxCleanConfig()
+			var err = c.ReadFormat(strings.NewReader(conf), FormatTOML)
+			if (err != nil) != tt.err {
+				t.Fatalf("ReadFormat() error = %v, want error %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if got := c.Influxdb1[0].Oldseries[0].Protect; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("job protect = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
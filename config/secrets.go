@@ -0,0 +1,113 @@
+// influxclean config package provides access to configuration files
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/tesibelda/influxclean/internal/redact"
+)
+
+// Redacted replaces secrets in configuration output
+const Redacted = redact.Redacted
+
+var envVariable = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// ExpandEnv replaces ${VAR} and ${VAR:-default} references with the value of
+// environment variable VAR, or default if VAR is unset or empty
+func ExpandEnv(s string) string {
+	return envVariable.ReplaceAllStringFunc(s, func(ref string) string {
+		var m = envVariable.FindStringSubmatch(ref)
+		if v := os.Getenv(m[1]); len(v) > 0 {
+			return v
+		}
+		return m[3]
+	})
+}
+
+// expandEnv expands environment variable references in all string settings
+func (c *InfluxCleanConfig) expandEnv() {
+	expandValue(reflect.ValueOf(c).Elem())
+}
+
+// expandValue expands environment variable references in the strings of v
+func expandValue(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.String:
+		if v.CanSet() {
+			v.SetString(ExpandEnv(v.String()))
+		}
+	case reflect.Struct:
+		if v.Type() == reflect.TypeOf(Source{}) {
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			expandValue(v.Field(i))
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			expandValue(v.Index(i))
+		}
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			var e = reflect.New(v.Type().Elem()).Elem()
+			e.Set(v.MapIndex(k))
+			expandValue(e)
+			v.SetMapIndex(k, e)
+		}
+	}
+}
+
+// readSecretFile returns the trimmed content of a secret file
+func readSecretFile(path, desc string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("%s. %s could not be read: %v",
+			ErrorString_ParseFailed,
+			desc,
+			err,
+		)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// Secrets returns the passwords and tokens of the configuration, including
+// passwords in server urls, so they can be redacted from logs
+func (c *InfluxCleanConfig) Secrets() []string {
+	var secrets []string
+	for _, inf := range c.Influxdb1 {
+		secrets = append(secrets, inf.Secrets()...)
+	}
+	return secrets
+}
+
+// Secrets returns the password, token and url password of a server
+func (inf Influxdb1Info) Secrets() []string {
+	var secrets []string
+	for _, s := range []string{inf.Password, inf.Token, redact.URLPassword(inf.Url)} {
+		if len(s) > 0 {
+			secrets = append(secrets, s)
+		}
+	}
+	return secrets
+}
+
+// Redact replaces passwords and tokens in the configuration with Redacted
+func (c *InfluxCleanConfig) Redact() {
+	for i := range c.Influxdb1 {
+		var inf = &c.Influxdb1[i]
+		for _, s := range []*string{&inf.Password, &inf.Token} {
+			if len(*s) > 0 {
+				*s = Redacted
+			}
+		}
+		inf.Url = redact.URL(inf.Url)
+	}
+}
@@ -0,0 +1,120 @@
+// influxclean config package provides access to configuration files
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Selection restricts the servers, jobs and databases to run. Empty lists and
+// selector select everything
+type Selection struct {
+	Servers   []string
+	Jobs      []string
+	Databases []string
+	Selector  map[string]string
+}
+
+// ParseSelector parses a label selector like team=windows,env=prod
+func ParseSelector(s string) (map[string]string, error) {
+	var sel = make(map[string]string)
+	for _, kv := range strings.Split(s, ",") {
+		if len(strings.TrimSpace(kv)) == 0 {
+			continue
+		}
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || len(strings.TrimSpace(k)) == 0 {
+			return nil, fmt.Errorf("invalid selector %s, expected label=value", kv)
+		}
+		sel[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return sel, nil
+}
+
+// Select removes from the config the servers and jobs not selected and restricts
+// the databases of the remaining jobs. It fails if a selected server or job
+// name does not match any entry or nothing is left to run
+func (c *InfluxCleanConfig) Select(s Selection) error {
+	var servers []Influxdb1Info
+	var matched = make(map[string]bool)
+
+	for _, inf := range c.Influxdb1 {
+		if len(s.Servers) > 0 && !selectServer(inf, s.Servers, matched) {
+			continue
+		}
+		var jobs []OldSeriesInfo
+		for _, job := range inf.Oldseries {
+			if len(s.Jobs) > 0 && !selectName(job.Name, s.Jobs, matched) {
+				continue
+			}
+			if !job.matchLabels(s.Selector) {
+				continue
+			}
+			if len(s.Databases) > 0 {
+				if job.Databases = selectDatabases(job.Databases, s.Databases); len(job.Databases) == 0 {
+					continue
+				}
+			}
+			jobs = append(jobs, job)
+		}
+		if len(jobs) > 0 {
+			inf.Oldseries = jobs
+			servers = append(servers, inf)
+		}
+	}
+	for _, name := range append(append([]string{}, s.Servers...), s.Jobs...) {
+		if !matched[name] {
+			return fmt.Errorf("No server or job matches %s", name)
+		}
+	}
+	if len(servers) == 0 {
+		return fmt.Errorf("No jobs match the given selection")
+	}
+	c.Influxdb1 = servers
+	return nil
+}
+
+// matchLabels reports whether the job labels include all the selector labels
+func (job OldSeriesInfo) matchLabels(selector map[string]string) bool {
+	for k, v := range selector {
+		if lv, ok := job.Labels[k]; !ok || lv != v {
+			return false
+		}
+	}
+	return true
+}
+
+func selectServer(inf Influxdb1Info, names []string, matched map[string]bool) bool {
+	return selectName(inf.Url, names, matched) || selectName(inf.Name, names, matched)
+}
+
+func selectName(name string, names []string, matched map[string]bool) bool {
+	for _, n := range names {
+		if len(name) > 0 && n == name {
+			matched[n] = true
+			return true
+		}
+	}
+	return false
+}
+
+// selectDatabases returns the job databases restricted to the selected ones,
+// or the selected ones if the job runs on all databases
+func selectDatabases(dbs, selected []string) []string {
+	if len(dbs) == 0 {
+		return selected
+	}
+	var list []string
+	for _, db := range dbs {
+		for _, s := range selected {
+			if db == s {
+				list = append(list, db)
+			}
+		}
+	}
+	return list
+}
@@ -0,0 +1,100 @@
+// influxclean config package provides access to configuration files
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+package config
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/tesibelda/influxclean/internal/redact"
+)
+
+// inherit fills the settings not set in a job from its template chain, then
+// from the server defaults and their template chain and last from its preset
+func (job *OldSeriesInfo) inherit(templates map[string]OldSeriesInfo, defaults OldSeriesInfo) {
+	var bases = templateChain(job.Extends, templates)
+	bases = append(bases, defaults)
+	bases = append(bases, templateChain(defaults.Extends, templates)...)
+	for _, base := range bases {
+		fillUnset(job, base)
+	}
+	job.applyPreset()
+}
+
+// templateChain returns the templates extended from name, stopping at unknown
+// names or cycles which are reported by templateProblems
+func templateChain(name string, templates map[string]OldSeriesInfo) []OldSeriesInfo {
+	var chain []OldSeriesInfo
+	var seen = make(map[string]bool)
+	for len(name) > 0 && !seen[name] {
+		seen[name] = true
+		t, ok := templates[name]
+		if !ok {
+			break
+		}
+		chain = append(chain, t)
+		name = t.Extends
+	}
+	return chain
+}
+
+// fillUnset sets the fields of job with zero value to the ones in base, except
+// those identifying the job. Booleans are pointers so that a job setting them
+// to false is not filled
+func fillUnset(job *OldSeriesInfo, base OldSeriesInfo) {
+	var jv = reflect.ValueOf(job).Elem()
+	var bv = reflect.ValueOf(base)
+	for i := 0; i < jv.NumField(); i++ {
+		switch jv.Type().Field(i).Name {
+		case "Name", "Extends", "Source":
+			continue
+		}
+		if jv.Field(i).IsZero() {
+			jv.Field(i).Set(bv.Field(i))
+		}
+	}
+}
+
+// templateProblems returns an error for each unknown or cyclic template
+// extended by templates, server defaults or jobs
+func (c *InfluxCleanConfig) templateProblems() []error {
+	var errs []error
+	var check = func(name, desc string) {
+		var seen = make(map[string]bool)
+		for len(name) > 0 {
+			if seen[name] {
+				errs = append(errs, fmt.Errorf("%s. %s extends template %s in a cycle",
+					ErrorString_ParseFailed,
+					desc,
+					name,
+				))
+				return
+			}
+			seen[name] = true
+			t, ok := c.Templates[name]
+			if !ok {
+				errs = append(errs, fmt.Errorf("%s. %s extends unknown template %s",
+					ErrorString_ParseFailed,
+					desc,
+					name,
+				))
+				return
+			}
+			name = t.Extends
+		}
+	}
+
+	for name, t := range c.Templates {
+		check(t.Extends, "Template "+name)
+	}
+	for _, inf := range c.Influxdb1 {
+		check(inf.Defaults.Extends, "Defaults of "+redact.URL(inf.Url))
+		for _, job := range inf.Oldseries {
+			check(job.Extends, "Job "+job.Name)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,71 @@
+// influxclean config package provides access to configuration files
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+const templatesConfig = `
+[templates.base]
+  drop_from_all = true
+  tags = ["host"]
+  current_window = ["72h", "1m"]
+[[influxdb1]]
+  url = "http://localhost:8086"
+  [influxdb1.defaults]
+    rp = "autogen"
+  [[influxdb1.oldseries]]
+    name = "explicit false"
+    extends = "base"
+    measurement = "cpu"
+    drop_from_all = false
+  [[influxdb1.oldseries]]
+    name = "inherited"
+    extends = "base"
+    measurement = "mem"
+`
+
+func TestInheritKeepsExplicitFalse(t *testing.T) {
+	var c = NewInfluxCleanConfig()
+	if err := c.ReadFormat(strings.NewReader(templatesConfig), FormatTOML); err != nil {
+		t.Fatal(err)
+	}
+	var jobs = c.Influxdb1[0].Oldseries
+	if jobs[0].DropsFromAll() {
+		t.Errorf("job %s drops from all measurements", jobs[0].Name)
+	}
+	if !jobs[1].DropsFromAll() {
+		t.Errorf("job %s does not inherit drop_from_all", jobs[1].Name)
+	}
+	for _, job := range jobs {
+		if job.Rp != "autogen" || len(job.Tags) != 1 {
+			t.Errorf("job %s did not inherit rp and tags: %+v", job.Name, job)
+		}
+	}
+}
+
+func TestMergeKeepsExplicitFalse(t *testing.T) {
+	var yes, no = true, false
+	var tests = []struct {
+		first, later *bool
+		want         bool
+	}{
+		{nil, &yes, true},
+		{&no, &yes, false},
+		{&yes, &no, true},
+		{nil, nil, false},
+	}
+	for _, tt := range tests {
+		var inf = Influxdb1Info{Url: "u", Insecure_skip_verify: tt.first}
+		inf.merge(Influxdb1Info{Url: "u", Insecure_skip_verify: tt.later})
+		if inf.SkipsVerify() != tt.want {
+			t.Errorf("merge of %v and %v skips verify = %v, want %v",
+				tt.first, tt.later, inf.SkipsVerify(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+// influxclean config package provides access to configuration files
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tesibelda/influxclean/internal/timewindow"
+)
+
+// parseWindows parses the history and current windows of an oldseries job and
+// checks the current window is restricted and overlaps the history window
+func parseWindows(job OldSeriesInfo) error {
+	var hb, he, cb, ce time.Time
+	var err error
+
+	var now = time.Now()
+	if hb, he, err = parseWindow(job.History_window, "History", now); err != nil {
+		return err
+	}
+	if cb, ce, err = parseWindow(job.Current_window, "Current", now); err != nil {
+		return err
+	}
+	if cb.IsZero() {
+		return fmt.Errorf("%s. Current window of job %s has no time restriction so no series would ever be old",
+			ErrorString_ParseFailed,
+			job.Name,
+		)
+	}
+	if !hb.IsZero() && (!cb.Before(he) || !hb.Before(ce)) {
+		return fmt.Errorf("%s. Current window of job %s (%s) does not overlap its history window (%s)",
+			ErrorString_ParseFailed,
+			job.Name,
+			DescribeWindow(job.Current_window),
+			DescribeWindow(job.History_window),
+		)
+	}
+	return nil
+}
+
+// parseWindow parses a time window config entry returning its absolute begin and
+// end times relative to now, or zero times if the window has no time restriction
+func parseWindow(w []string, desc string, now time.Time) (time.Time, time.Time, error) {
+	var b, e timewindow.Bound
+	var err error
+
+	if len(w) != 2 {
+		return time.Time{}, time.Time{}, fmt.Errorf("%s. %s window should include two durations or times",
+			ErrorString_ParseFailed,
+			desc,
+		)
+	}
+	for k, bound := range []*timewindow.Bound{&b, &e} {
+		if *bound, err = timewindow.ParseBound(w[k]); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("%s. %s time window could not be parsed: %v",
+				ErrorString_ParseFailed,
+				desc,
+				err,
+			)
+		}
+	}
+	if b.IsNow() && e.IsNow() {
+		return time.Time{}, time.Time{}, nil
+	}
+	if !b.Time(now).Before(e.Time(now)) {
+		return time.Time{}, time.Time{}, fmt.Errorf("%s. %s window is not from older to newer (%s)",
+			ErrorString_ParseFailed,
+			desc,
+			DescribeWindow(w),
+		)
+	}
+	return b.Time(now), e.Time(now), nil
+}
+
+// DescribeWindow explains a time window in plain language
+func DescribeWindow(w []string) string {
+	if len(w) != 2 {
+		return fmt.Sprintf("invalid window %v", w)
+	}
+	b, errb := timewindow.ParseBound(w[0])
+	e, erre := timewindow.ParseBound(w[1])
+	switch {
+	case errb != nil || erre != nil:
+		return fmt.Sprintf("invalid window %v", w)
+	case b.IsNow() && e.IsNow():
+		return "any time (no time restriction)"
+	}
+	return fmt.Sprintf("from %s until %s", describeBound(b), describeBound(e))
+}
+
+func describeBound(b timewindow.Bound) string {
+	switch {
+	case b.IsAbsolute():
+		return b.String()
+	case b.IsNow():
+		return "now"
+	}
+	return b.String() + " ago"
+}
@@ -0,0 +1,98 @@
+// influxclean config package provides access to configuration files
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseWindow(t *testing.T) {
+	var now = time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	var tests = []struct {
+		name       string
+		w          []string
+		begin, end time.Time
+		err        string
+	}{
+		{"relative", []string{"72h", "1m"}, now.Add(-72 * time.Hour), now.Add(-time.Minute), ""},
+		{"no time restriction", []string{"0m", "0s"}, time.Time{}, time.Time{}, ""},
+		{"one element", []string{"72h"}, time.Time{}, time.Time{}, "should include two"},
+		{"three elements", []string{"72h", "1m", "0s"}, time.Time{}, time.Time{}, "should include two"},
+		{"begin newer than end", []string{"1m", "72h"}, time.Time{}, time.Time{}, "not from older to newer"},
+		{"same bounds", []string{"1h", "60m"}, time.Time{}, time.Time{}, "not from older to newer"},
+		{"unparsable", []string{"72x", "1m"}, time.Time{}, time.Time{}, "could not be parsed"},
+		{
+			"absolute and relative",
+			[]string{"2024-01-01T00:00:00Z", "1d"},
+			time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			now.Add(-24 * time.Hour),
+			"",
+		},
+		{"absolute after relative", []string{"1d", "2024-01-01T00:00:00Z"}, time.Time{}, time.Time{}, "not from older to newer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b, e, err = parseWindow(tt.w, "History", now)
+			switch {
+			case len(tt.err) == 0 && err != nil:
+				t.Fatalf("parseWindow(%v) error = %v", tt.w, err)
+			case len(tt.err) > 0 && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Fatalf("parseWindow(%v) error = %v, want %q", tt.w, err, tt.err)
+			}
+			if !b.Equal(tt.begin) || !e.Equal(tt.end) {
+				t.Errorf("parseWindow(%v) = %v, %v, want %v, %v", tt.w, b, e, tt.begin, tt.end)
+			}
+		})
+	}
+}
+
+func TestParseWindows(t *testing.T) {
+	var tests = []struct {
+		name             string
+		history, current []string
+		err              string
+	}{
+		{"overlapping", []string{"0m", "0m"}, []string{"72h", "1m"}, ""},
+		{"history restricted", []string{"30d", "1h"}, []string{"72h", "1m"}, ""},
+		{"current without time restriction", []string{"30d", "1h"}, []string{"0m", "0m"}, "no time restriction"},
+		{"current newer than history", []string{"30d", "7d"}, []string{"72h", "1m"}, "does not overlap"},
+		{"current older than history", []string{"72h", "1m"}, []string{"30d", "7d"}, "does not overlap"},
+		{"invalid history", []string{"1m", "72h"}, []string{"72h", "1m"}, "History window is not from older to newer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var job = OldSeriesInfo{Name: "test", History_window: tt.history, Current_window: tt.current}
+			var err = parseWindows(job)
+			switch {
+			case len(tt.err) == 0 && err != nil:
+				t.Errorf("parseWindows() error = %v", err)
+			case len(tt.err) > 0 && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Errorf("parseWindows() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestDescribeWindow(t *testing.T) {
+	var tests = []struct {
+		w    []string
+		want string
+	}{
+		{[]string{"72h", "1m"}, "from 72h ago until 1m ago"},
+		{[]string{"0m", "0m"}, "any time (no time restriction)"},
+		{[]string{"1w2d", "0s"}, "from 1w2d ago until now"},
+		{[]string{"2024-01-01T00:00:00Z", "1d"}, "from 2024-01-01T00:00:00Z until 1d ago"},
+		{[]string{"72h"}, "invalid window [72h]"},
+		{[]string{"72x", "1m"}, "invalid window [72x 1m]"},
+	}
+	for _, tt := range tests {
+		if got := DescribeWindow(tt.w); got != tt.want {
+			t.Errorf("DescribeWindow(%v) = %q, want %q", tt.w, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+// influxclean influxdb1 package provides access to InfluxDB v1.x
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+package influxdb1
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Condition is a structured tag comparison used to narrow queries and drops
+type Condition struct {
+	Tag      string
+	Operator string
+	Value    string
+}
+
+var negatedOperators = map[string]string{
+	"=":  "!=",
+	"!=": "=",
+	"=~": "!~",
+	"!~": "=~",
+}
+
+// Negate returns the condition with the opposite operator
+func (c Condition) Negate() Condition {
+	c.Operator = negatedOperators[c.Operator]
+	return c
+}
+
+// String renders the condition with quoted tag key and escaped value
+func (c Condition) String() string {
+	var val string
+	switch c.Operator {
+	case "=~", "!~":
+		val = QuoteRegex(c.Value)
+	default:
+		val = QuoteValue(c.Value)
+	}
+	return fmt.Sprintf("%s %s %s", QuoteIdent(c.Tag), c.Operator, val)
+}
+
+// RenderConditions renders the conditions joined with AND
+func RenderConditions(conds []Condition) string {
+	var parts = make([]string, 0, len(conds))
+	for _, c := range conds {
+		parts = append(parts, c.String())
+	}
+	return strings.Join(parts, " AND ")
+}
+
+// JoinFilters joins the non empty filter expressions with AND, enclosing each
+// of them in parenthesis when there is more than one
+func JoinFilters(filters ...string) string {
+	var parts []string
+	for _, f := range filters {
+		if len(f) > 0 {
+			parts = append(parts, f)
+		}
+	}
+	if len(parts) < 2 {
+		return strings.Join(parts, "")
+	}
+	return "(" + strings.Join(parts, ") AND (") + ")"
+}
+
+// QuoteIdent returns the identifier enclosed in double quotes
+func QuoteIdent(s string) string {
+	var r = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// Unquote returns the identifier s without its enclosing double quotes and
+// escapes, or s if it is not quoted
+func Unquote(s string) string {
+	if len(s) < 2 || !strings.HasPrefix(s, `"`) || !strings.HasSuffix(s, `"`) {
+		return s
+	}
+	var r = strings.NewReplacer(`\\`, `\`, `\"`, `"`, `\n`, "\n")
+	return r.Replace(s[1 : len(s)-1])
+}
+
+// QuoteValue returns the string literal enclosed in single quotes
+func QuoteValue(s string) string {
+	var r = strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\n", `\n`)
+	return "'" + r.Replace(s) + "'"
+}
+
+// QuoteRegex returns the regular expression enclosed in slashes, escaping the
+// slashes not already escaped and a trailing backslash. Other escape sequences
+// are kept as written, as InfluxQL passes them to the regular expression
+func QuoteRegex(s string) string {
+	var b strings.Builder
+	b.WriteByte('/')
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s):
+			b.WriteString(s[i : i+2])
+			i++
+		case s[i] == '\\':
+			b.WriteString(`\\`)
+		case s[i] == '/':
+			b.WriteString(`\/`)
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	b.WriteByte('/')
+	return b.String()
+}
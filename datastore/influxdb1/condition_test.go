@@ -0,0 +1,126 @@
+// influxclean influxdb1 package provides access to InfluxDB v1.x
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+package influxdb1
+
+import "testing"
+
+func TestQuoteValue(t *testing.T) {
+	var tests = []struct {
+		in, want string
+	}{
+		{"web01", `'web01'`},
+		{"", `''`},
+		{"o'neil", `'o\'neil'`},
+		{`c:\temp`, `'c:\\temp'`},
+		{`\'`, `'\\\''`},
+		{`say "hi"`, `'say "hi"'`},
+		{"two\nlines", `'two\nlines'`},
+		{"a/b", `'a/b'`},
+	}
+	for _, tt := range tests {
+		if got := QuoteValue(tt.in); got != tt.want {
+			t.Errorf("QuoteValue(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestQuoteIdent(t *testing.T) {
+	var tests = []struct {
+		in, want string
+	}{
+		{"host", `"host"`},
+		{`my "db"`, `"my \"db\""`},
+		{`a\b`, `"a\\b"`},
+		{"it's", `"it's"`},
+	}
+	for _, tt := range tests {
+		if got := QuoteIdent(tt.in); got != tt.want {
+			t.Errorf("QuoteIdent(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestQuoteRegex(t *testing.T) {
+	var tests = []struct {
+		in, want string
+	}{
+		{"^prod-", `/^prod-/`},
+		{"a/b", `/a\/b/`},
+		{`a\/b`, `/a\/b/`},
+		{`^\d+\.\d+$`, `/^\d+\.\d+$/`},
+		{`a\\/b`, `/a\\\/b/`},
+		{`end\`, `/end\\/`},
+		{"//", `/\/\//`},
+	}
+	for _, tt := range tests {
+		if got := QuoteRegex(tt.in); got != tt.want {
+			t.Errorf("QuoteRegex(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestConditionString(t *testing.T) {
+	var tests = []struct {
+		c    Condition
+		want string
+	}{
+		{Condition{"host", "=", "web01"}, `"host" = 'web01'`},
+		{Condition{"host", "!=", "o'neil"}, `"host" != 'o\'neil'`},
+		{Condition{"path", "=", `c:\temp`}, `"path" = 'c:\\temp'`},
+		{Condition{"host", "=~", "^test-"}, `"host" =~ /^test-/`},
+		{Condition{"url", "!~", "^/api/v1"}, `"url" !~ /^\/api\/v1/`},
+		{Condition{"url", "=~", `^\/api`}, `"url" =~ /^\/api/`},
+		{Condition{`my "tag"`, "=", "x"}, `"my \"tag\"" = 'x'`},
+	}
+	for _, tt := range tests {
+		if got := tt.c.String(); got != tt.want {
+			t.Errorf("%+v.String() = %s, want %s", tt.c, got, tt.want)
+		}
+	}
+}
+
+func TestConditionNegate(t *testing.T) {
+	for op, neg := range map[string]string{"=": "!=", "!=": "=", "=~": "!~", "!~": "=~"} {
+		var c = Condition{"host", op, "x"}.Negate()
+		if c.Operator != neg {
+			t.Errorf("Negate of %s = %s, want %s", op, c.Operator, neg)
+		}
+	}
+}
+
+func TestDropSeriesStatement(t *testing.T) {
+	var where = `host=` + QuoteValue("o'neil")
+	var tests = []struct {
+		m, f, want string
+	}{
+		{"", "", `DROP SERIES WHERE host='o\'neil'`},
+		{"cpu", "", `DROP SERIES FROM "cpu" WHERE host='o\'neil'`},
+		{"cpu", `"dc" = 'x'`, `DROP SERIES FROM "cpu" WHERE (host='o\'neil') AND "dc" = 'x'`},
+	}
+	for _, tt := range tests {
+		if got := dropSeriesStatement(tt.m, where, tt.f); got != tt.want {
+			t.Errorf("dropSeriesStatement(%q, %q) = %s, want %s", tt.m, tt.f, got, tt.want)
+		}
+	}
+}
+
+func TestUnquote(t *testing.T) {
+	var tests = []struct{ in, want string }{
+		{"cpu", "cpu"},
+		{`"cpu.total"`, "cpu.total"},
+		{`"say \"hi\""`, `say "hi"`},
+		{`"back\\slash"`, `back\slash`},
+		{`"`, `"`},
+	}
+	for _, tt := range tests {
+		if got := Unquote(tt.in); got != tt.want {
+			t.Errorf("Unquote(%s) = %s, want %s", tt.in, got, tt.want)
+		}
+		if got := Unquote(QuoteIdent(tt.want)); got != tt.want {
+			t.Errorf("Unquote(QuoteIdent(%s)) = %s", tt.want, got)
+		}
+	}
+}
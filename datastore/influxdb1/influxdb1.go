@@ -6,18 +6,31 @@
 package influxdb1
 
 import (
+	"crypto/tls"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/influxdata/influxdb1-client/models"
 	client "github.com/influxdata/influxdb1-client/v2"
 
+	"github.com/tesibelda/influxclean/audit"
+	"github.com/tesibelda/influxclean/backup"
+	"github.com/tesibelda/influxclean/internal/redact"
+	"github.com/tesibelda/influxclean/internal/timewindow"
 	"github.com/tesibelda/influxclean/log"
 )
 
 type Influxdb1Client struct {
 	con    client.Client
 	Log    *log.Logger
+	Audit  *audit.Log
+	Backup *backup.Writer
 	url    string
 	user   string
 	dryrun bool
@@ -25,6 +38,18 @@ type Influxdb1Client struct {
 
 var Separator = "#"
 
+// AuthError is returned when the server rejects the credentials with HTTP
+// status 401 or 403
+type AuthError struct {
+	Status int
+	Msg    string
+}
+
+// Error returns the status and the error message of the server
+func (e *AuthError) Error() string {
+	return fmt.Sprintf("server rejected the credentials with status %d: %s", e.Status, e.Msg)
+}
+
 // Open opens a connection to the provided influxdb1
 func (ic *Influxdb1Client) Open(url, user, password string, skip bool, dry bool) error {
 	var err error
@@ -47,10 +72,69 @@ func (ic *Influxdb1Client) Open(url, user, password string, skip bool, dry bool)
 	if err != nil {
 		return err
 	}
-	ic.Log.Debugf("Connected to %s version %s", url, ver)
+	if err = checkAuth(conf, timeout); err != nil {
+		return err
+	}
+	ic.Log.Debugf("Connected to %s version %s", redact.URL(url), ver)
 	return err
 }
 
+// checkAuth runs SHOW DATABASES with the credentials of conf and returns an
+// AuthError if the server answers with HTTP status 401 or 403, as the client
+// does not expose the status of its responses
+func checkAuth(conf client.HTTPConfig, timeout time.Duration) error {
+	u, err := url.Parse(conf.Addr)
+	if err != nil {
+		return err
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/query"
+	u.RawQuery = url.Values{"q": {"SHOW DATABASES"}}.Encode()
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	if len(conf.Username) > 0 || len(conf.Password) > 0 {
+		req.SetBasicAuth(conf.Username, conf.Password)
+	}
+	var hc = &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: conf.InsecureSkipVerify},
+		},
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		var body struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&body)
+		return &AuthError{Status: resp.StatusCode, Msg: body.Error}
+	}
+	return nil
+}
+
+// logStatement logs a statement about to run with its database and measurement
+func (ic *Influxdb1Client) logStatement(msg, db, m, statement string) {
+	var fields = log.Fields{"statement": statement}
+	if len(db) > 0 {
+		fields["db"] = db
+	}
+	if len(m) > 0 {
+		fields["measurement"] = m
+	}
+	ic.Log.With(fields).Debug(msg)
+}
+
+// SetDryrun enables or disables dry run mode, in which drops are skipped
+func (ic *Influxdb1Client) SetDryrun(dry bool) {
+	ic.dryrun = dry
+}
+
 // Close closes the opened connection
 func (ic *Influxdb1Client) Close() {
 	ic.con.Close()
@@ -88,14 +172,14 @@ func (ic *Influxdb1Client) QueryShowTagValues(db, rp, m, d1, f string) ([]string
 	var err error
 
 	// use Sprintf as client.NewQueryWithParameters does not work with all versions
-	query = fmt.Sprintf("SHOW TAG VALUES FROM %s WITH KEY=%s", m, d1)
+	query = fmt.Sprintf("SHOW TAG VALUES FROM %s WITH KEY=%s", QuoteIdent(m), d1)
 	if len(f) > 0 {
 		query = fmt.Sprintf("%s WHERE %s", query, f)
 	}
 	q = client.NewQuery(query, db, "")
 	q.RetentionPolicy = rp
 
-	ic.Log.Debugf("querying: %s", q.Command)
+	ic.logStatement("querying", db, m, q.Command)
 	if response, err = ic.con.Query(q); err != nil {
 		return nil, err
 	}
@@ -108,164 +192,597 @@ func (ic *Influxdb1Client) QueryShowTagValues(db, rp, m, d1, f string) ([]string
 	return rowShowSlice(bogus), err
 }
 
-// Query1Dim return the list of values for a tag with data in the given time window
-func (ic *Influxdb1Client) Query1Dim(db, rp, m, p, d1, f, rb, re string) ([]string, error) {
+// QueryShowMeasurements returns the list of measurement names, optionally
+// restricted to those matching regular expression re
+func (ic *Influxdb1Client) QueryShowMeasurements(db, re string) ([]string, error) {
+	var query = "SHOW MEASUREMENTS"
+	if len(re) > 0 {
+		query = fmt.Sprintf("%s WITH MEASUREMENT =~ %s", query, QuoteRegex(re))
+	}
+	return ic.queryShow(db, "", query, "show measurements")
+}
+
+// QueryShowFieldKeys returns the list of field keys of a measurement
+func (ic *Influxdb1Client) QueryShowFieldKeys(db, rp, m string) ([]string, error) {
+	var query = fmt.Sprintf("SHOW FIELD KEYS FROM %s", QuoteIdent(m))
+	return ic.queryShow(db, rp, query, "show field keys")
+}
+
+// ExpandField returns the fields a field setting stands for in measurement m,
+// which are all its field keys quoted if it is "*" or the field itself
+func (ic *Influxdb1Client) ExpandField(db, rp, m, field string) ([]string, error) {
+	if field != "*" {
+		return []string{field}, nil
+	}
+	var keys, err = ic.QueryShowFieldKeys(db, rp, m)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no field keys found for measurement %s in %s db", m, db)
+	}
+	var fields = make([]string, 0, len(keys))
+	for _, key := range keys {
+		fields = append(fields, QuoteIdent(key))
+	}
+	return fields, nil
+}
+
+// QueryShowRetentionPolicies returns the list of retention policy names of a database
+func (ic *Influxdb1Client) QueryShowRetentionPolicies(db string) ([]string, error) {
+	var query = fmt.Sprintf("SHOW RETENTION POLICIES ON %s", QuoteIdent(db))
+	return ic.queryShow(db, "", query, "show retention policies")
+}
+
+// QueryShowTagKeys returns the list of tag keys of a measurement
+func (ic *Influxdb1Client) QueryShowTagKeys(db, rp, m string) ([]string, error) {
+	var query = fmt.Sprintf("SHOW TAG KEYS FROM %s", QuoteIdent(m))
+	return ic.queryShow(db, rp, query, "show tag keys")
+}
+
+// QuerySeriesCardinality returns the number of series of a measurement
+func (ic *Influxdb1Client) QuerySeriesCardinality(db, rp, m string) (int64, error) {
+	var query = fmt.Sprintf("SHOW SERIES CARDINALITY FROM %s", QuoteIdent(m))
+	return ic.queryCount(db, rp, query, "show series cardinality")
+}
+
+// QueryTagValuesCardinality returns the number of values of a tag key in a
+// measurement
+func (ic *Influxdb1Client) QueryTagValuesCardinality(db, rp, m, key string) (int64, error) {
+	var query = fmt.Sprintf("SHOW TAG VALUES CARDINALITY FROM %s WITH KEY = %s", QuoteIdent(m), QuoteIdent(key))
+	return ic.queryCount(db, rp, query, "show tag values cardinality")
+}
+
+// queryCount runs a cardinality query and returns the count of its first serie
+func (ic *Influxdb1Client) queryCount(db, rp, query, desc string) (int64, error) {
+	var response *client.Response
+	var err error
+
+	var q = client.NewQuery(query, db, "")
+	q.RetentionPolicy = rp
+	ic.logStatement("querying", db, "", q.Command)
+	if response, err = ic.con.Query(q); err != nil {
+		return 0, err
+	}
+	if response.Error() != nil {
+		return 0, fmt.Errorf("Query %s failed: %s", desc, response.Error())
+	}
+	if len(response.Results[0].Series) == 0 || len(response.Results[0].Series[0].Values) == 0 {
+		return 0, nil
+	}
+	var point = response.Results[0].Series[0].Values[0]
+	switch v := point[len(point)-1].(type) {
+	case json.Number:
+		return v.Int64()
+	case float64:
+		return int64(v), nil
+	}
+	return 0, fmt.Errorf("Query %s returned an unexpected count %v", desc, point[len(point)-1])
+}
+
+// queryShow runs a SHOW query and returns the values of its first serie
+func (ic *Influxdb1Client) queryShow(db, rp, query, desc string) ([]string, error) {
 	var bogus models.Row
+	var response *client.Response
+	var err error
+
+	var q = client.NewQuery(query, db, "")
+	q.RetentionPolicy = rp
+	ic.logStatement("querying", db, "", q.Command)
+	if response, err = ic.con.Query(q); err != nil {
+		return nil, err
+	}
+	if response.Error() != nil {
+		return nil, fmt.Errorf("Query %s failed: %s", desc, response.Error())
+	}
+	if len(response.Results[0].Series) > 0 {
+		bogus = response.Results[0].Series[0]
+	}
+	return rowShowSlice(bogus), err
+}
+
+// Query1Dim return the list of values for a tag with data in any of the fields
+// in the given time window
+func (ic *Influxdb1Client) Query1Dim(db, rp, m string, fields []string, d1, f, rb, re string) ([]string, error) {
 	var q client.Query
 	var response *client.Response
 	var query string
 	var err error
 
-	wb, _ := time.ParseDuration(rb)
-	we, _ := time.ParseDuration(re)
-	if wb == we {
-		cd, _ := time.ParseDuration("0m")
-		if wb == cd {
-			return ic.QueryShowTagValues(db, rp, m, d1, f)
-		}
+	var where = timeCondition(rb, re)
+	if len(where) == 0 {
+		return ic.QueryShowTagValues(db, rp, m, d1, f)
 	}
 
 	// use Sprintf as client.NewQueryWithParameters does not work with all versions
-	query = fmt.Sprintf("SELECT %s FROM (SELECT first(%s), %s::tag AS %s FROM %s WHERE (time > now() - %s AND time < now() - %s)", d1, p, d1, d1, m, rb, re)
+	query = fmt.Sprintf("SELECT %s FROM %s WHERE %s", selectors("first", fields), QuoteIdent(m), where)
 	if len(f) > 0 {
 		query = fmt.Sprintf("%s AND %s", query, f)
 	}
-	query = fmt.Sprintf("%s GROUP BY %s)", query, d1)
+	query = fmt.Sprintf("%s GROUP BY %s", query, d1)
 	q = client.NewQuery(query, db, "")
 	q.RetentionPolicy = rp
 
-	ic.Log.Debugf("querying: %s", q.Command)
+	ic.logStatement("querying", db, m, q.Command)
 	if response, err = ic.con.Query(q); err != nil {
 		return nil, err
 	}
 	if response.Error() != nil {
 		return nil, fmt.Errorf("Query with dimension %s failed: %s", d1, response.Error())
 	}
-	if len(response.Results[0].Series) > 0 {
-		bogus = response.Results[0].Series[0]
-	}
-	return rowSelectSlice(bogus), err
+	return rowsTagSlice(response.Results[0].Series, d1), err
 }
 
 // Query2Dims return the list of values for the combination of two tags with data
-// in the given time window
-func (ic *Influxdb1Client) Query2Dims(db, rp, m, p, d1, d2, f, rb, re string) ([]string, error) {
+// in any of the fields in the given time window
+func (ic *Influxdb1Client) Query2Dims(db, rp, m string, fields []string, d1, d2, f, rb, re string) ([]string, error) {
 	var (
-		bogus        models.Row
 		q            client.Query
 		response     *client.Response
 		query, where string
 		err          error
 	)
 
-	query = fmt.Sprintf("SELECT %s, %s FROM (SELECT first(%s), %s::tag AS %s, %s::tag AS %s FROM %s", d1, d2, p, d1, d1, d2, d2, m)
+	query = fmt.Sprintf("SELECT %s FROM %s", selectors("first", fields), QuoteIdent(m))
 
-	where = fmt.Sprintf("(time > now() - %s AND time < now() - %s)", rb, re)
-	wb, _ := time.ParseDuration(rb)
-	we, _ := time.ParseDuration(re)
-	if wb == we {
-		cd, _ := time.ParseDuration("0m")
-		if wb == cd {
-			where = ""
-		}
-	}
-	switch len(f) {
-	case 0:
-		if len(where) > 0 {
-			where = fmt.Sprintf("WHERE %s", where)
-		}
-	default:
+	where = timeCondition(rb, re)
+	switch {
+	case len(f) > 0 && len(where) > 0:
 		where = fmt.Sprintf("WHERE %s AND %s", where, f)
+	case len(f) > 0:
+		where = fmt.Sprintf("WHERE %s", f)
+	case len(where) > 0:
+		where = fmt.Sprintf("WHERE %s", where)
 	}
-	query = fmt.Sprintf("%s %s GROUP BY %s, %s)", query, where, d1, d2)
+	query = fmt.Sprintf("%s %s GROUP BY %s, %s", query, where, d1, d2)
 
 	q = client.NewQuery(query, db, "")
 	q.RetentionPolicy = rp
 
-	ic.Log.Debugf("querying: %s", q.Command)
+	ic.logStatement("querying", db, m, q.Command)
 	if response, err = ic.con.Query(q); err != nil {
 		return nil, err
 	}
 	if response.Error() != nil {
 		return nil, fmt.Errorf("Query with dimensions %s and %s failed: %s", d1, d2, response.Error())
 	}
-	if len(response.Results[0].Series) > 0 {
-		bogus = response.Results[0].Series[0]
+	return rowsTagSlice(response.Results[0].Series, d1, d2), err
+}
+
+// QueryLastSeen returns the time of the last point of any of the fields in
+// measurement m for each combination of the given tags, keyed by tag values
+// joined with Separator. Each field is queried on its own, as InfluxDB returns
+// no time for queries with several selectors
+func (ic *Influxdb1Client) QueryLastSeen(db, rp, m string,
+	fields []string,
+	tags []string,
+	f string,
+) (map[string]time.Time, error) {
+	var seen = make(map[string]time.Time)
+	for _, p := range fields {
+		var fseen, err = ic.queryLastSeenField(db, rp, m, p, tags, f)
+		if err != nil {
+			return nil, err
+		}
+		for s, t := range fseen {
+			if t.After(seen[s]) {
+				seen[s] = t
+			}
+		}
 	}
-	return rowSelectSlice(bogus), err
+	return seen, nil
 }
 
-func (ic *Influxdb1Client) DropSeries1Dim(db, m, dim string, vals []string) error {
-	var q client.Query
+// queryLastSeenField returns the time of the last point of field p in
+// measurement m for each combination of the given tags
+func (ic *Influxdb1Client) queryLastSeenField(db, rp, m, p string,
+	tags []string,
+	f string,
+) (map[string]time.Time, error) {
 	var response *client.Response
 	var query string
 	var err error
 
-	switch len(m) {
-	case 0:
-		query = "DROP SERIES WHERE"
-	default:
-		query = fmt.Sprintf("DROP SERIES FROM %s WHERE", m)
+	query = fmt.Sprintf("SELECT last(%s) FROM %s", p, QuoteIdent(m))
+	if len(f) > 0 {
+		query = fmt.Sprintf("%s WHERE %s", query, f)
 	}
-	for i, val := range vals {
-		if i > 0 {
-			query = fmt.Sprintf("%s OR", query)
+	query = fmt.Sprintf("%s GROUP BY %s", query, strings.Join(tags, ", "))
+	var q = client.NewQuery(query, db, "")
+	q.RetentionPolicy = rp
+
+	ic.logStatement("querying", db, m, q.Command)
+	if response, err = ic.con.Query(q); err != nil {
+		return nil, err
+	}
+	if response.Error() != nil {
+		return nil, fmt.Errorf("Query last seen failed: %s", response.Error())
+	}
+	var seen = make(map[string]time.Time)
+	for _, row := range response.Results[0].Series {
+		if len(row.Values) == 0 || len(row.Values[0]) == 0 {
+			continue
+		}
+		ts, ok := row.Values[0][0].(string)
+		if !ok {
+			continue
+		}
+		// epoch times come from queries without a point time, not from data
+		t, err := time.Parse(time.RFC3339Nano, ts)
+		if err != nil || t.Unix() == 0 {
+			continue
 		}
-		query = fmt.Sprintf("%s %s='%s'", query, dim, val)
+		var vals = make([]string, 0, len(tags))
+		for _, tag := range tags {
+			vals = append(vals, row.Tags[tag])
+		}
+		seen[strings.Join(vals, Separator)] = t
 	}
-	q = client.NewQuery(query, db, "")
+	return seen, nil
+}
+
+// DropSeries1Dim drops series with the given tag values, optionally narrowed
+// by filter expression f
+func (ic *Influxdb1Client) DropSeries1Dim(db, m, dim string, vals []string, f string) error {
+	var where string
 
-	ic.Log.Debugf("dropping: %s", q.Command)
-	switch ic.dryrun {
-	case false:
-		response, err = ic.con.Query(q)
-		if err == nil && response.Error() != nil {
-			return fmt.Errorf("Dropping series failed: %s", response.Error())
+	for i, val := range vals {
+		if i > 0 {
+			where = fmt.Sprintf("%s OR ", where)
 		}
-	case true:
-		ic.Log.Debug("dryrun mode on, drops skipped")
+		where = fmt.Sprintf("%s%s=%s", where, dim, QuoteValue(val))
 	}
-	return err
+	return ic.execDrop(db, m, where, f, len(vals))
 }
 
+// DropSeries2Dims drops series with the given pairs of tag values, optionally
+// narrowed by filter expression f
 func (ic *Influxdb1Client) DropSeries2Dims(db, m, d1 string,
 	vals1 []string,
 	d2 string,
 	vals2 []string,
+	f string,
 ) error {
-	var q client.Query
-	var response *client.Response
-	var query string
-	var err error
+	var where string
 
 	if len(vals1) != len(vals2) {
 		return fmt.Errorf("Received different size lists for the two tag values")
 	}
+	for i, val1 := range vals1 {
+		if i > 0 {
+			where = fmt.Sprintf("%s OR ", where)
+		}
+		where = fmt.Sprintf("%s(%s=%s AND %s=%s)",
+			where,
+			d1,
+			QuoteValue(val1),
+			d2,
+			QuoteValue(vals2[i]),
+		)
+	}
+	return ic.execDrop(db, m, where, f, len(vals1))
+}
+
+// execDrop drops the series of measurement m matching predicate where and
+// filter f, with the given number of tuples, unless dry run is enabled. Their
+// points are saved first in the backup file if set. With an audit log set, a
+// pending entry is recorded before the drop and another with its result after
+// it, and failures recording them are returned as AuditError
+func (ic *Influxdb1Client) execDrop(db, m, where, f string, tuples int) error {
+	var err error
+
+	var q = client.NewQuery(dropSeriesStatement(m, where, f), db, "")
+	var entry = audit.Entry{
+		Server:    redact.URL(ic.url),
+		Db:        db,
+		Statement: q.Command,
+		Tuples:    tuples,
+		Dryrun:    ic.dryrun,
+	}
+	ic.logStatement("dropping", db, m, q.Command)
+	if ic.dryrun {
+		ic.Log.Debug("dryrun mode on, drops skipped")
+		entry.Result = audit.ResultDryrun
+		return ic.record(entry, false)
+	}
+
+	if ic.Backup != nil {
+		if err = ic.backupSeries(db, m, seriesPredicate(where, f)); err != nil {
+			return fmt.Errorf("Backing up series failed, drop skipped: %w", err)
+		}
+	}
+	entry.Result = audit.ResultPending
+	if err = ic.record(entry, false); err != nil {
+		return err
+	}
+	var response *client.Response
+	response, err = ic.con.Query(q)
+	if err == nil && response.Error() != nil {
+		err = fmt.Errorf("Dropping series failed: %s", response.Error())
+	}
+	entry.Result = audit.ResultOK
+	if err != nil {
+		entry.Result = "failed: " + err.Error()
+	}
+	if aerr := ic.record(entry, err == nil); err == nil {
+		err = aerr
+	}
+	return err
+}
+
+// AuditError is returned when a drop could not be recorded in the audit log,
+// with Dropped set if the series were dropped anyway
+type AuditError struct {
+	Dropped bool
+	Err     error
+}
+
+// Error returns the error recording the drop
+func (e *AuditError) Error() string {
+	if e.Dropped {
+		return fmt.Sprintf("Series dropped but recording the result in audit log failed: %v", e.Err)
+	}
+	return fmt.Sprintf("Recording drop in audit log failed, drop skipped: %v", e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *AuditError) Unwrap() error {
+	return e.Err
+}
+
+// record records an entry in the audit log if set, returning an AuditError with
+// dropped if it fails
+func (ic *Influxdb1Client) record(e audit.Entry, dropped bool) error {
+	if ic.Audit == nil {
+		return nil
+	}
+	if err := ic.Audit.Record(e); err != nil {
+		return &AuditError{Dropped: dropped, Err: err}
+	}
+	return nil
+}
+
+// backupSeries writes the points of the series of measurement m, or all
+// measurements if empty, matching predicate p to the backup file. All retention
+// policies are exported, as DROP SERIES drops the series from all of them
+func (ic *Influxdb1Client) backupSeries(db, m, p string) error {
+	var from = "/.*/"
+	if len(m) > 0 {
+		from = QuoteIdent(m)
+	}
+	rps, err := ic.QueryShowRetentionPolicies(db)
+	if err != nil {
+		return err
+	}
+	for _, rp := range rps {
+		types, err := ic.queryFieldTypes(db, rp, from)
+		if err != nil {
+			return err
+		}
+		var q = client.NewQuery(fmt.Sprintf("SELECT * FROM %s WHERE %s GROUP BY *", from, p), db, "ns")
+		q.RetentionPolicy = rp
+		q.Chunked = true
+		q.ChunkSize = 10000
+		ic.logStatement("backing up", db, m, q.Command)
+		cr, err := ic.con.QueryAsChunk(q)
+		if err != nil {
+			return err
+		}
+		err = ic.backupChunks(db, rp, cr, types)
+		cr.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return ic.Backup.Sync()
+}
+
+// backupChunks writes the rows of a chunked response to the backup file as
+// line protocol, with field values typed by measurement in types
+func (ic *Influxdb1Client) backupChunks(db, rp string,
+	cr *client.ChunkedResponse,
+	types map[string]map[string]string,
+) error {
+	for {
+		response, err := cr.NextResponse()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if response.Error() != nil {
+			return fmt.Errorf("Query series data failed: %s", response.Error())
+		}
+		for _, res := range response.Results {
+			for _, row := range res.Series {
+				lines, err := rowLines(row, types[row.Name])
+				if err != nil {
+					return err
+				}
+				if err = ic.Backup.Write(db, rp, lines...); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// queryFieldTypes returns the type of each field key by measurement for the
+// measurements of from
+func (ic *Influxdb1Client) queryFieldTypes(db, rp, from string) (map[string]map[string]string, error) {
+	var response *client.Response
+	var err error
+
+	var q = client.NewQuery(fmt.Sprintf("SHOW FIELD KEYS FROM %s", from), db, "")
+	q.RetentionPolicy = rp
+	ic.logStatement("querying", db, "", q.Command)
+	if response, err = ic.con.Query(q); err != nil {
+		return nil, err
+	}
+	if response.Error() != nil {
+		return nil, fmt.Errorf("Query show field keys failed: %s", response.Error())
+	}
+	var types = make(map[string]map[string]string)
+	for _, row := range response.Results[0].Series {
+		var mt = make(map[string]string)
+		for _, v := range row.Values {
+			if len(v) < 2 {
+				continue
+			}
+			key, _ := v[0].(string)
+			typ, _ := v[1].(string)
+			if _, ok := mt[key]; !ok {
+				mt[key] = typ
+			}
+		}
+		types[row.Name] = mt
+	}
+	return types, nil
+}
+
+// WriteLines writes points given as line protocol with nanosecond timestamps
+// to database db and retention policy rp, and returns the number of points.
+// With dry run enabled the points are only parsed
+func (ic *Influxdb1Client) WriteLines(db, rp string, lines []string) (int, error) {
+	var pts, err = models.ParsePointsWithPrecision([]byte(strings.Join(lines, "\n")), time.Now().UTC(), "ns")
+	if err != nil {
+		return 0, err
+	}
+	if ic.dryrun {
+		return len(pts), nil
+	}
+	bp, err := client.NewBatchPoints(client.BatchPointsConfig{
+		Database:        db,
+		RetentionPolicy: rp,
+		Precision:       "ns",
+	})
+	if err != nil {
+		return 0, err
+	}
+	for _, pt := range pts {
+		bp.AddPoint(client.NewPointFrom(pt))
+	}
+	ic.Log.With(log.Fields{"db": db, "rp": rp}).Debugf("writing %d points", len(pts))
+	if err = ic.con.Write(bp); err != nil {
+		return 0, err
+	}
+	return len(pts), nil
+}
+
+// timeCondition returns the time condition for a window from rb to re, which
+// are durations before now or RFC3339 times, or empty if both are zero durations
+func timeCondition(rb, re string) string {
+	b, _ := timewindow.ParseBound(rb)
+	e, _ := timewindow.ParseBound(re)
+	if b.IsNow() && e.IsNow() {
+		return ""
+	}
+	return fmt.Sprintf("(time > %s AND time < %s)", b.InfluxQL(), e.InfluxQL())
+}
+
+// dropSeriesStatement returns a DROP SERIES statement for the measurement m
+// (all measurements if empty) with the given series predicate and filter
+func dropSeriesStatement(m, where, f string) string {
+	var query string
 	switch len(m) {
 	case 0:
 		query = "DROP SERIES WHERE"
 	default:
-		query = fmt.Sprintf("DROP SERIES FROM %s WHERE", m)
+		query = fmt.Sprintf("DROP SERIES FROM %s WHERE", QuoteIdent(m))
 	}
-	for i, val1 := range vals1 {
-		if i > 0 {
-			query = fmt.Sprintf("%s OR", query)
+	return fmt.Sprintf("%s %s", query, seriesPredicate(where, f))
+}
+
+// seriesPredicate returns the series predicate where narrowed by filter f
+func seriesPredicate(where, f string) string {
+	if len(f) > 0 {
+		return fmt.Sprintf("(%s) AND %s", where, f)
+	}
+	return where
+}
+
+// rowLines returns the points of a row of a SELECT * ... GROUP BY * query as
+// line protocol with nanosecond timestamps, with field values of the given
+// types. Empty tag values and null field values are left out
+func rowLines(row models.Row, types map[string]string) ([]string, error) {
+	var tags = make(map[string]string)
+	for k, v := range row.Tags {
+		if len(v) > 0 {
+			tags[k] = v
 		}
-		query = fmt.Sprintf("%s (%s='%s' AND %s='%s')", query, d1, val1, d2, vals2[i])
 	}
-	q = client.NewQuery(query, db, "")
+	var lines = make([]string, 0, len(row.Values))
+	for _, point := range row.Values {
+		var ts time.Time
+		var fields = make(map[string]interface{})
+		for j, col := range row.Columns {
+			if j >= len(point) || point[j] == nil {
+				continue
+			}
+			if col == "time" {
+				n, _ := point[j].(json.Number)
+				ns, err := n.Int64()
+				if err != nil {
+					return nil, fmt.Errorf("unexpected time %v", point[j])
+				}
+				ts = time.Unix(0, ns).UTC()
+				continue
+			}
+			v, err := fieldValue(point[j], types[col])
+			if err != nil {
+				return nil, fmt.Errorf("field %s of %s: %w", col, row.Name, err)
+			}
+			fields[col] = v
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		p, err := client.NewPoint(row.Name, tags, fields, ts)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, p.String())
+	}
+	return lines, nil
+}
 
-	ic.Log.Debugf("dropping: %s", q.Command)
-	switch ic.dryrun {
-	case false:
-		response, err = ic.con.Query(q)
-		if err == nil && response.Error() != nil {
-			return fmt.Errorf("Dropping series failed: %s", response.Error())
+// fieldValue returns a field value decoded from a query response as the Go
+// type of field type typ, so it is written back with the same type
+func fieldValue(v interface{}, typ string) (interface{}, error) {
+	switch x := v.(type) {
+	case string, bool:
+		return x, nil
+	case json.Number:
+		switch typ {
+		case "integer":
+			return x.Int64()
+		case "unsigned":
+			return strconv.ParseUint(x.String(), 10, 64)
 		}
-	case true:
-		ic.Log.Debug("dryrun mode on, drop skipped")
+		return x.Float64()
 	}
-	return err
+	return nil, fmt.Errorf("unexpected value %v", v)
 }
 
 func rowShowSlice(row models.Row) []string {
@@ -274,7 +791,8 @@ func rowShowSlice(row models.Row) []string {
 	for _, point := range row.Values {
 		for j, column := range row.Columns {
 			col = string(column)
-			if col == "value" || col == "name" {
+			switch col {
+			case "value", "name", "fieldKey", "tagKey":
 				record = point[j].(string)
 			}
 		}
@@ -285,24 +803,30 @@ func rowShowSlice(row models.Row) []string {
 	return data
 }
 
-func rowSelectSlice(row models.Row) []string {
+// selectors returns a call to selector function fn for each of the fields
+func selectors(fn string, fields []string) string {
+	var calls = make([]string, 0, len(fields))
+	for _, p := range fields {
+		calls = append(calls, fmt.Sprintf("%s(%s)", fn, p))
+	}
+	return strings.Join(calls, ", ")
+}
+
+// rowsTagSlice returns the values of the given tags in each row of a query
+// grouped by them, joined with Separator. Rows without a value for any of the
+// tags are skipped, as dropping by an empty tag value would match other series
+func rowsTagSlice(rows []models.Row, tags ...string) []string {
 	var data []string
-	var record string
-	for _, point := range row.Values {
-		for j, column := range row.Columns {
-			if string(column) != "time" && point[j] != nil {
-				var actual = point[j].(string)
-				switch len(record) {
-				case 0:
-					record = actual
-				default:
-					record = record + Separator + actual
-				}
+	for _, row := range rows {
+		var vals = make([]string, 0, len(tags))
+		for _, tag := range tags {
+			if v := row.Tags[tag]; len(v) > 0 {
+				vals = append(vals, v)
 			}
 		}
-		data = append(data, record)
-		record = ""
+		if len(vals) == len(tags) {
+			data = append(data, strings.Join(vals, Separator))
+		}
 	}
-
 	return data
 }
@@ -0,0 +1,272 @@
+// influxclean influxdb1 package provides access to InfluxDB v1.x
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+package influxdb1
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tesibelda/influxclean/audit"
+	"github.com/tesibelda/influxclean/backup"
+	"github.com/tesibelda/influxclean/log"
+)
+
+// fakeServer answers pings and the queries starting with each key of responses
+// with its JSON value, recording the queries received. If status is set,
+// queries are answered with it and an authorization error
+type fakeServer struct {
+	status    int
+	responses map[string]string
+	queries   []string
+	writes    []string
+}
+
+func (fs *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/ping" {
+		w.Header().Set("X-Influxdb-Version", "1.8.10")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.URL.Path == "/write" {
+		var b, _ = io.ReadAll(r.Body)
+		fs.writes = append(fs.writes, r.URL.Query().Get("rp")+": "+string(b))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	var q = r.FormValue("q")
+	fs.queries = append(fs.queries, q)
+	w.Header().Set("Content-Type", "application/json")
+	if fs.status != 0 {
+		w.WriteHeader(fs.status)
+		w.Write([]byte(`{"error":"authorization failed"}`))
+		return
+	}
+	for prefix, body := range fs.responses {
+		if strings.HasPrefix(q, prefix) {
+			w.Write([]byte(body))
+			return
+		}
+	}
+	w.Write([]byte(`{"results":[{"statement_id":0}]}`))
+}
+
+func openFake(t *testing.T, responses map[string]string) (*Influxdb1Client, *fakeServer) {
+	var fs = &fakeServer{responses: responses}
+	var srv = httptest.NewServer(fs)
+	t.Cleanup(srv.Close)
+	var ic = &Influxdb1Client{Log: log.NewLogger(false)}
+	if err := ic.Open(srv.URL, "", "", false, true); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(ic.Close)
+	fs.queries = nil
+	return ic, fs
+}
+
+const fieldKeys = `{"results":[{"statement_id":0,"series":[{"name":"docker_container_cpu",
+	"columns":["fieldKey","fieldType"],
+	"values":[["usage_percent","float"],["throttling periods","integer"]]}]}]}`
+
+func TestOpenAuth(t *testing.T) {
+	for _, status := range []int{http.StatusUnauthorized, http.StatusForbidden} {
+		var srv = httptest.NewServer(&fakeServer{status: status})
+		var ic = &Influxdb1Client{Log: log.NewLogger(false)}
+		var err = ic.Open(srv.URL, "admin", "wrong", false, true)
+		srv.Close()
+		var ae *AuthError
+		if !errors.As(err, &ae) || ae.Status != status || ae.Msg != "authorization failed" {
+			t.Errorf("Open() with status %d = %v, want AuthError", status, err)
+		}
+	}
+}
+
+func TestExpandField(t *testing.T) {
+	var ic, _ = openFake(t, map[string]string{"SHOW FIELD KEYS": fieldKeys})
+
+	fields, err := ic.ExpandField("telegraf", "", "docker_container_cpu", "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want = []string{`"usage_percent"`, `"throttling periods"`}
+	if !reflect.DeepEqual(fields, want) {
+		t.Errorf("ExpandField(*) = %v, want %v", fields, want)
+	}
+	if fields, _ = ic.ExpandField("telegraf", "", "cpu", "usage_idle"); !reflect.DeepEqual(fields, []string{"usage_idle"}) {
+		t.Errorf("ExpandField(usage_idle) = %v", fields)
+	}
+}
+
+func TestQueryDims(t *testing.T) {
+	var ic, fs = openFake(t, map[string]string{"SELECT": `{"results":[{"statement_id":0,"series":[
+		{"name":"docker_container_cpu","tags":{"host":"h1","container_name":"c1"},"columns":["time","first","first_1"],"values":[["1970-01-01T00:00:00Z",1,null]]},
+		{"name":"docker_container_cpu","tags":{"host":"h1","container_name":""},"columns":["time","first","first_1"],"values":[["1970-01-01T00:00:00Z",1,2]]},
+		{"name":"docker_container_cpu","tags":{"host":"h2","container_name":"c2"},"columns":["time","first","first_1"],"values":[["1970-01-01T00:00:00Z",null,2]]}]}]}`})
+	var fields = []string{`"usage_percent"`, `"throttling periods"`}
+
+	got, err := ic.Query2Dims("telegraf", "", "docker_container_cpu", fields, "host", "container_name", "", "72h", "1m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"h1#c1", "h2#c2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Query2Dims = %v, want %v", got, want)
+	}
+	var wantq = `SELECT first("usage_percent"), first("throttling periods") FROM "docker_container_cpu" ` +
+		`WHERE (time > now() - 72h AND time < now() - 1m) GROUP BY host, container_name`
+	if fs.queries[0] != wantq {
+		t.Errorf("Query2Dims query = %s, want %s", fs.queries[0], wantq)
+	}
+
+	got, err = ic.Query1Dim("telegraf", "", "docker_container_cpu", fields, "host", `"env" = 'prod'`, "72h", "1m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"h1", "h1", "h2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Query1Dim = %v, want %v", got, want)
+	}
+	wantq = `SELECT first("usage_percent"), first("throttling periods") FROM "docker_container_cpu" ` +
+		`WHERE (time > now() - 72h AND time < now() - 1m) AND "env" = 'prod' GROUP BY host`
+	if fs.queries[1] != wantq {
+		t.Errorf("Query1Dim query = %s, want %s", fs.queries[1], wantq)
+	}
+}
+func TestQueryLastSeen(t *testing.T) {
+	var ic, fs = openFake(t, map[string]string{
+		`SELECT last("a")`: `{"results":[{"statement_id":0,"series":[
+			{"name":"m","tags":{"host":"h1"},"columns":["time","last"],"values":[["2024-01-02T00:00:00Z",1]]},
+			{"name":"m","tags":{"host":"h2"},"columns":["time","last"],"values":[["2024-01-05T00:00:00Z",1]]}]}]}`,
+		`SELECT last("b")`: `{"results":[{"statement_id":0,"series":[
+			{"name":"m","tags":{"host":"h1"},"columns":["time","last"],"values":[["2024-01-03T00:00:00Z",1]]},
+			{"name":"m","tags":{"host":"h2"},"columns":["time","last"],"values":[["1970-01-01T00:00:00Z",1]]}]}]}`,
+	})
+
+	seen, err := ic.QueryLastSeen("telegraf", "", "m", []string{`"a"`, `"b"`}, []string{"host"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want = map[string]time.Time{
+		"h1": time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC),
+		"h2": time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC),
+	}
+	if !reflect.DeepEqual(seen, want) {
+		t.Errorf("QueryLastSeen = %v, want %v", seen, want)
+	}
+	if len(fs.queries) != 2 {
+		t.Errorf("QueryLastSeen ran %d queries, want one per field", len(fs.queries))
+	}
+}
+
+func TestDropBackup(t *testing.T) {
+	var ic, fs = openFake(t, map[string]string{
+		"SHOW RETENTION POLICIES": `{"results":[{"statement_id":0,"series":[{"columns":["name","duration"],"values":[["autogen","0s"]]}]}]}`,
+		"SHOW FIELD KEYS":         fieldKeys,
+		"SELECT *": `{"results":[{"statement_id":0,"series":[{"name":"docker_container_cpu","tags":{"host":"h1","container_name":""},
+			"columns":["time","throttling periods","usage_percent"],"values":[[1000,3,0.5],[2000,null,1],[3000,null,null]]}]}]}`,
+	})
+	b, err := backup.Create(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ic.Backup = b
+	ic.SetDryrun(false)
+
+	if err = ic.DropSeries1Dim("telegraf", "docker_container_cpu", "host", []string{"h1"}, `"env" = 'test'`); err != nil {
+		t.Fatal(err)
+	}
+	var want = []string{
+		`SHOW RETENTION POLICIES ON "telegraf"`,
+		`SHOW FIELD KEYS FROM "docker_container_cpu"`,
+		`SELECT * FROM "docker_container_cpu" WHERE (host='h1') AND "env" = 'test' GROUP BY *`,
+		`DROP SERIES FROM "docker_container_cpu" WHERE (host='h1') AND "env" = 'test'`,
+	}
+	if !reflect.DeepEqual(fs.queries, want) {
+		t.Errorf("queries = %q, want %q", fs.queries, want)
+	}
+	if err = b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(b.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file = `# DML
+# CONTEXT-DATABASE:telegraf
+# CONTEXT-RETENTION-POLICY:autogen
+docker_container_cpu,host=h1 throttling\ periods=3i,usage_percent=0.5 1000
+docker_container_cpu,host=h1 usage_percent=1 2000
+`
+	if string(data) != file {
+		t.Errorf("backup file =\n%s\nwant\n%s", data, file)
+	}
+
+	f, err := os.Open(b.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var n int
+	err = backup.Read(f, 10, func(db, rp string, lines []string) error {
+		var written, err = ic.WriteLines(db, rp, lines)
+		n += written
+		return err
+	})
+	if err != nil || n != 2 {
+		t.Fatalf("restored %d points: %v", n, err)
+	}
+	var writes = []string{"autogen: " + strings.Join(strings.Split(file, "\n")[3:], "\n")}
+	if !reflect.DeepEqual(fs.writes, writes) {
+		t.Errorf("writes = %q, want %q", fs.writes, writes)
+	}
+}
+
+func TestDropAudit(t *testing.T) {
+	var ic, fs = openFake(t, nil)
+	var path = t.TempDir() + "/audit.jsonl"
+	a, err := audit.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ic.Audit = a
+	ic.SetDryrun(false)
+
+	if err = ic.DropSeries1Dim("telegraf", "cpu", "host", []string{"h1"}, ""); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var results []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var e audit.Entry
+		if err = json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatal(err)
+		}
+		results = append(results, e.Result)
+	}
+	if want := []string{audit.ResultPending, audit.ResultOK}; !reflect.DeepEqual(results, want) {
+		t.Errorf("audit results = %q, want %q", results, want)
+	}
+
+	// without a pending entry the drop must not run
+	a.Close()
+	fs.queries = nil
+	err = ic.DropSeries1Dim("telegraf", "cpu", "host", []string{"h2"}, "")
+	var ae *AuditError
+	if !errors.As(err, &ae) || ae.Dropped {
+		t.Errorf("DropSeries1Dim() with closed audit log = %v, want AuditError not dropped", err)
+	}
+	if len(fs.queries) > 0 {
+		t.Errorf("queries = %q, want none", fs.queries)
+	}
+}
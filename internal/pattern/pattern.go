@@ -0,0 +1,130 @@
+// pattern package provides matching of values against exact, glob or regex patterns
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+package pattern
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Pattern is a compiled exact, glob or regular expression pattern
+type Pattern struct {
+	raw   string
+	regex *regexp.Regexp
+}
+
+// Compile compiles a pattern string. Patterns enclosed in slashes (/^prod-/)
+// are regular expressions, patterns with *, ? or [ are globs and anything
+// else must match exactly. In globs * and ? match any character, slashes
+// included, and a backslash matches the next character literally
+func Compile(s string) (*Pattern, error) {
+	var p = &Pattern{raw: s}
+	var err error
+
+	switch {
+	case len(s) > 1 && strings.HasPrefix(s, "/") && strings.HasSuffix(s, "/"):
+		if p.regex, err = regexp.Compile(s[1 : len(s)-1]); err != nil {
+			return nil, fmt.Errorf("invalid regex pattern %s: %w", s, err)
+		}
+	case strings.ContainsAny(s, "*?["):
+		if p.regex, err = globRegexp(s); err != nil {
+			return nil, fmt.Errorf("invalid glob pattern %s: %w", s, err)
+		}
+	}
+	return p, nil
+}
+
+// globRegexp returns the regular expression matching the whole values matched
+// by glob g, as path.Match does not let * match slashes
+func globRegexp(g string) (*regexp.Regexp, error) {
+	if _, err := path.Match(g, ""); err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(g); i++ {
+		switch g[i] {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		case '\\':
+			i++
+			b.WriteString(regexp.QuoteMeta(g[i : i+1]))
+		case '[':
+			b.WriteString("[")
+			if i+1 < len(g) && g[i+1] == '^' {
+				b.WriteString("^")
+				i++
+			}
+			for i++; g[i] != ']'; i++ {
+				switch {
+				case g[i] == '-':
+					b.WriteByte('-')
+				case g[i] == '\\':
+					i++
+					b.WriteString(classChar(g[i]))
+				default:
+					b.WriteString(classChar(g[i]))
+				}
+			}
+			b.WriteString("]")
+		default:
+			b.WriteString(regexp.QuoteMeta(g[i : i+1]))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
+
+// classChar returns byte c to be matched literally in a regexp class, leaving
+// alphanumeric and non ASCII bytes as they are
+func classChar(c byte) string {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c >= 0x80:
+		return string([]byte{c})
+	}
+	return `\` + string([]byte{c})
+}
+
+// CompileList compiles a list of pattern strings
+func CompileList(list []string) ([]*Pattern, error) {
+	var ps = make([]*Pattern, 0, len(list))
+	for _, s := range list {
+		p, err := Compile(s)
+		if err != nil {
+			return nil, err
+		}
+		ps = append(ps, p)
+	}
+	return ps, nil
+}
+
+// Match reports whether the value matches the pattern
+func (p *Pattern) Match(v string) bool {
+	switch {
+	case p.regex != nil:
+		return p.regex.MatchString(v)
+	}
+	return p.raw == v
+}
+
+// String returns the pattern as written in the configuration
+func (p *Pattern) String() string {
+	return p.raw
+}
+
+// MatchAny reports whether the value matches any of the patterns
+func MatchAny(ps []*Pattern, v string) bool {
+	for _, p := range ps {
+		if p.Match(v) {
+			return true
+		}
+	}
+	return false
+}
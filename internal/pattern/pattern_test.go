@@ -0,0 +1,80 @@
+// pattern package provides matching of values against exact, glob or regex patterns
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+package pattern
+
+import (
+	"testing"
+)
+
+func TestCompile(t *testing.T) {
+	var tests = []struct {
+		pattern string
+		err     bool
+	}{
+		{"db01", false},
+		{"/", false},
+		{"//", false},
+		{"/^prod-/", false},
+		{"/(prod/", true},
+		{"dc1-*", false},
+		{"host[1-3]", false},
+		{"host[", true},
+		{"host[a-]", true},
+		{`host\`, false},
+		{`host*\`, true},
+		{`host\[1\]`, false},
+	}
+	for _, tt := range tests {
+		var _, err = Compile(tt.pattern)
+		if (err != nil) != tt.err {
+			t.Errorf("Compile(%q) error = %v, want error %v", tt.pattern, err, tt.err)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	var tests = []struct {
+		pattern string
+		value   string
+		want    bool
+	}{
+		{"db01", "db01", true},
+		{"db01", "db011", false},
+		{"db0.", "db01", false},
+		{"/", "/", true},
+		{"/", "a", false},
+		{"//", "anything", true},
+		{"/^prod-/", "prod-web", true},
+		{"/^prod-/", "preprod-web", false},
+		{"/web/", "prod-web-01", true},
+		{"dc1-*", "dc1-web", true},
+		{"dc1-*", "dc1-rack/web", true},
+		{"dc1-*", "dc2-web", false},
+		{"*-db", "prod.db", false},
+		{"host?", "host1", true},
+		{"host?", "hostñ", true},
+		{"host?", "host12", false},
+		{"host[1-3]", "host2", true},
+		{"host[1-3]", "host4", false},
+		{"host[^1-3]", "host4", true},
+		{"host[.]", "hostx", false},
+		{"host[.]", "host.", true},
+		{`host\[1\]`, "host[1]", true},
+		{`host\[1\]`, "host1", false},
+		{`db\*`, "db*", true},
+		{`db\*`, "db1", false},
+		{`host\`, `host\`, true},
+	}
+	for _, tt := range tests {
+		var p, err = Compile(tt.pattern)
+		if err != nil {
+			t.Fatalf("Compile(%q) error = %v", tt.pattern, err)
+		}
+		if got := p.Match(tt.value); got != tt.want {
+			t.Errorf("Compile(%q).Match(%q) = %v, want %v", tt.pattern, tt.value, got, tt.want)
+		}
+	}
+}
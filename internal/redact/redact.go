@@ -0,0 +1,38 @@
+// redact package provides helper functions to hide secrets
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+package redact
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Redacted replaces secrets in output
+const Redacted = "<redacted>"
+
+// URL returns the url with its password, if any, replaced with Redacted
+func URL(u string) string {
+	pu, err := url.Parse(u)
+	if err != nil || pu.User == nil {
+		return u
+	}
+	if _, ok := pu.User.Password(); !ok {
+		return u
+	}
+	pu.User = url.User(pu.User.Username())
+	var user = "//" + pu.User.String() + "@"
+	return strings.Replace(pu.String(), user, strings.TrimSuffix(user, "@")+":"+Redacted+"@", 1)
+}
+
+// URLPassword returns the password in the user info of a url, if any
+func URLPassword(u string) string {
+	pu, err := url.Parse(u)
+	if err != nil || pu.User == nil {
+		return ""
+	}
+	p, _ := pu.User.Password()
+	return p
+}
@@ -27,6 +27,16 @@ func ChunkSlice(slice []string, chunkSize int) [][]string {
 	return chunks
 }
 
+// Contains reports whether the slice contains the given item
+func Contains(slice []string, item string) bool {
+	for _, v := range slice {
+		if v == item {
+			return true
+		}
+	}
+	return false
+}
+
 // Difference returns items unique to slice1
 func Difference(slice1, slice2 []string) []string {
 	var diff []string
@@ -42,6 +52,25 @@ outer:
 	return diff
 }
 
+// Intersection returns items of slice1 also in slice2
+func Intersection(slice1, slice2 []string) []string {
+	var inter []string
+	for _, v := range slice1 {
+		if Contains(slice2, v) {
+			inter = append(inter, v)
+		}
+	}
+	return inter
+}
+
+// Union returns a new slice with slice1 followed by the items of slice2 not
+// in slice1
+func Union(slice1, slice2 []string) []string {
+	var union = make([]string, 0, len(slice1)+len(slice2))
+	union = append(union, slice1...)
+	return append(union, Difference(slice2, slice1)...)
+}
+
 // Split2Dims returns two slices splitting elements with the given separator
 func Split2Dims(ch []string, sep string) ([]string, []string) {
 	var vals, vals1, vals2 []string
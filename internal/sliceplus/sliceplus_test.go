@@ -0,0 +1,40 @@
+// sliceplus package provides helper functions for slices
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+package sliceplus
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUnion(t *testing.T) {
+	var tests = []struct {
+		s1, s2, want []string
+	}{
+		{nil, nil, []string{}},
+		{[]string{"a"}, nil, []string{"a"}},
+		{nil, []string{"a", "b"}, []string{"a", "b"}},
+		{[]string{"a", "b"}, []string{"b", "c"}, []string{"a", "b", "c"}},
+	}
+	for _, tt := range tests {
+		if got := Union(tt.s1, tt.s2); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Union(%v, %v) = %v, want %v", tt.s1, tt.s2, got, tt.want)
+		}
+	}
+}
+
+func TestUnionDoesNotAlias(t *testing.T) {
+	var base = make([]string, 1, 4)
+	base[0] = "a"
+	var u1 = Union(base, []string{"b"})
+	var u2 = Union(base, []string{"c"})
+	if u1[1] != "b" || u2[1] != "c" {
+		t.Errorf("Union results share storage: %v, %v", u1, u2)
+	}
+	if len(base) != 1 || cap(base) != 4 || base[:2][1] != "" {
+		t.Errorf("Union modified its first argument: %v", base[:2])
+	}
+}
@@ -0,0 +1,140 @@
+// timewindow package provides parsing and rendering of time window bounds
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+package timewindow
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Bound is a time window bound, either a duration before now or an absolute time
+type Bound struct {
+	raw string
+	ago time.Duration
+	at  time.Time
+}
+
+var (
+	durationPart = regexp.MustCompile(`^(\d*)(?:\.(\d*))?(ns|us|µs|ms|s|m|h|d|w)`)
+	unitDuration = map[string]time.Duration{
+		"ns": time.Nanosecond,
+		"us": time.Microsecond,
+		"µs": time.Microsecond,
+		"ms": time.Millisecond,
+		"s":  time.Second,
+		"m":  time.Minute,
+		"h":  time.Hour,
+		"d":  24 * time.Hour,
+		"w":  7 * 24 * time.Hour,
+	}
+	influxqlDuration = regexp.MustCompile(`^\d+(ns|u|µ|ms|s|m|h|d|w)$`)
+)
+
+// ParseBound parses a window bound given as a duration relative to now, with
+// units from ns to d (days) and w (weeks) that may be mixed (1w2d12h), or as an
+// RFC3339 timestamp (2024-01-01T00:00:00Z)
+func ParseBound(s string) (Bound, error) {
+	var b = Bound{raw: s}
+	var err error
+
+	if b.at, err = time.Parse(time.RFC3339, s); err == nil {
+		return b, nil
+	}
+	if b.ago, err = ParseDuration(s); err != nil {
+		return b, fmt.Errorf("%s is neither a duration nor an RFC3339 time", s)
+	}
+	return b, nil
+}
+
+// ParseDuration parses a duration like time.ParseDuration does, including
+// signs and decimal numbers (1.5h), also accepting d (days) and w (weeks) units
+func ParseDuration(s string) (time.Duration, error) {
+	var d time.Duration
+	var rest = s
+	var neg bool
+	if len(rest) > 0 && (rest[0] == '-' || rest[0] == '+') {
+		neg = rest[0] == '-'
+		rest = rest[1:]
+	}
+	if len(rest) == 0 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	if rest == "0" {
+		return 0, nil
+	}
+	for len(rest) > 0 {
+		var m = durationPart.FindStringSubmatch(rest)
+		if m == nil || len(m[1])+len(m[2]) == 0 {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		var unit = unitDuration[m[3]]
+		var v time.Duration
+		if len(m[1]) > 0 {
+			n, err := strconv.ParseInt(m[1], 10, 64)
+			if err != nil || n > math.MaxInt64/int64(unit) {
+				return 0, fmt.Errorf("invalid duration %q: out of range", s)
+			}
+			v = time.Duration(n) * unit
+		}
+		if len(m[2]) > 0 {
+			f, _ := strconv.ParseFloat("0."+m[2], 64)
+			v += time.Duration(f * float64(unit))
+		}
+		if d += v; d < 0 {
+			return 0, fmt.Errorf("invalid duration %q: out of range", s)
+		}
+		rest = rest[len(m[0]):]
+	}
+	if neg {
+		return -d, nil
+	}
+	return d, nil
+}
+
+// IsAbsolute reports whether the bound is an absolute time
+func (b Bound) IsAbsolute() bool {
+	return !b.at.IsZero()
+}
+
+// IsNow reports whether the bound is a zero duration before now
+func (b Bound) IsNow() bool {
+	return !b.IsAbsolute() && b.ago == 0
+}
+
+// Time returns the absolute time of the bound relative to now
+func (b Bound) Time(now time.Time) time.Time {
+	if b.IsAbsolute() {
+		return b.at
+	}
+	return now.Add(-b.ago)
+}
+
+// InfluxQL returns the bound as an InfluxQL time expression
+func (b Bound) InfluxQL() string {
+	if b.IsAbsolute() {
+		return "'" + b.at.UTC().Format(time.RFC3339Nano) + "'"
+	}
+	if influxqlDuration.MatchString(b.raw) {
+		return "now() - " + b.raw
+	}
+	var op, ago = "-", b.ago
+	if ago < 0 {
+		op, ago = "+", -ago
+	}
+	if ago%time.Second == 0 {
+		return fmt.Sprintf("now() %s %ds", op, ago/time.Second)
+	}
+	return fmt.Sprintf("now() %s %dns", op, ago.Nanoseconds())
+}
+
+// String returns the bound as written in the configuration
+func (b Bound) String() string {
+	return strings.TrimSpace(b.raw)
+}
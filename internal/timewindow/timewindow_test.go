@@ -0,0 +1,107 @@
+// timewindow package provides parsing and rendering of time window bounds
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+package timewindow
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	var tests = []struct {
+		in   string
+		want time.Duration
+		ok   bool
+	}{
+		{"0", 0, true},
+		{"0s", 0, true},
+		{"0m", 0, true},
+		{"72h", 72 * time.Hour, true},
+		{"1h30m", 90 * time.Minute, true},
+		{"1.5h", 90 * time.Minute, true},
+		{".5h", 30 * time.Minute, true},
+		{"1.h", time.Hour, true},
+		{"2.25m", 135 * time.Second, true},
+		{"1d", 24 * time.Hour, true},
+		{"1.5d", 36 * time.Hour, true},
+		{"1w2d12h", 9*24*time.Hour + 12*time.Hour, true},
+		{"500ms", 500 * time.Millisecond, true},
+		{"10us", 10 * time.Microsecond, true},
+		{"10µs", 10 * time.Microsecond, true},
+		{"-1h", -time.Hour, true},
+		{"+1h", time.Hour, true},
+		{"", 0, false},
+		{"-", 0, false},
+		{"1", 0, false},
+		{"h", 0, false},
+		{".h", 0, false},
+		{"1x", 0, false},
+		{"1h 30m", 0, false},
+		{"1..5h", 0, false},
+		{"99999999999w", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseDuration(tt.in)
+		switch {
+		case tt.ok && err != nil:
+			t.Errorf("ParseDuration(%q) failed: %v", tt.in, err)
+		case !tt.ok && err == nil:
+			t.Errorf("ParseDuration(%q) = %v, want error", tt.in, got)
+		case got != tt.want:
+			t.Errorf("ParseDuration(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseDurationMatchesStdlib(t *testing.T) {
+	for _, s := range []string{"1.5h", "300ms", "-1.5h", "2h45m", "1h0.5m", "0.001s", "1.000000001s"} {
+		want, err := time.ParseDuration(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := ParseDuration(s); err != nil || got != want {
+			t.Errorf("ParseDuration(%q) = %v, %v, want %v", s, got, err, want)
+		}
+	}
+}
+
+func TestParseBound(t *testing.T) {
+	var now = time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	var tests = []struct {
+		in       string
+		time     time.Time
+		influxql string
+		isNow    bool
+	}{
+		{"0m", now, "now() - 0m", true},
+		{"72h", now.Add(-72 * time.Hour), "now() - 72h", false},
+		{"1w", now.Add(-7 * 24 * time.Hour), "now() - 1w", false},
+		{"1w2d", now.Add(-9 * 24 * time.Hour), "now() - 777600s", false},
+		{"1.5h", now.Add(-90 * time.Minute), "now() - 5400s", false},
+		{"1.5s", now.Add(-1500 * time.Millisecond), "now() - 1500000000ns", false},
+		{"-1h", now.Add(time.Hour), "now() + 3600s", false},
+		{"2024-01-01T00:00:00Z", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), "'2024-01-01T00:00:00Z'", false},
+	}
+	for _, tt := range tests {
+		b, err := ParseBound(tt.in)
+		if err != nil {
+			t.Errorf("ParseBound(%q) failed: %v", tt.in, err)
+			continue
+		}
+		if got := b.Time(now); !got.Equal(tt.time) {
+			t.Errorf("ParseBound(%q).Time = %v, want %v", tt.in, got, tt.time)
+		}
+		if got := b.InfluxQL(); got != tt.influxql {
+			t.Errorf("ParseBound(%q).InfluxQL = %s, want %s", tt.in, got, tt.influxql)
+		}
+		if b.IsNow() != tt.isNow {
+			t.Errorf("ParseBound(%q).IsNow = %v, want %v", tt.in, b.IsNow(), tt.isNow)
+		}
+	}
+	if _, err := ParseBound("yesterday"); err == nil {
+		t.Errorf("ParseBound(yesterday) did not fail")
+	}
+}
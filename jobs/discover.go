@@ -0,0 +1,133 @@
+// influxclean jobs package is responsible for launching queries and drops
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+package jobs
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/tesibelda/influxclean/config"
+	"github.com/tesibelda/influxclean/datastore/influxdb1"
+	"github.com/tesibelda/influxclean/internal/redact"
+	"github.com/tesibelda/influxclean/internal/sliceplus"
+	"github.com/tesibelda/influxclean/log"
+)
+
+// layout is a known Telegraf measurement layout, detected by the measurements
+// and tags of its preset, with the oldseries job settings suggested for it
+type layout struct {
+	desc        string
+	preset      string
+	dropFromAll bool
+	current     []string
+}
+
+// telegrafLayouts are the layouts detected by Discover
+var telegrafLayouts = []layout{
+	{"Linux hosts", "telegraf-linux", true, []string{"72h", "1m"}},
+	{"Windows hosts", "telegraf-windows", true, []string{"72h", "1m"}},
+	{"Docker containers", "telegraf-docker", false, []string{"24h", "1m"}},
+	{"Kubernetes pods", "telegraf-kubernetes", false, []string{"24h", "1m"}},
+	{"vSphere virtual machines", "telegraf-vsphere", false, []string{"72h", "1m"}},
+}
+
+// Suggestion is an oldseries job proposed for a layout found in a database
+type Suggestion struct {
+	Layout   string   // description of the layout
+	Matched  []string // measurements matching the layout
+	Job      config.OldSeriesInfo
+	Problems []string // reasons the layout may not fit
+}
+
+// DiscoveredDatabase is the result of discovering a database
+type DiscoveredDatabase struct {
+	Name         string
+	Measurements int
+	Suggestions  []Suggestion
+}
+
+// Discover connects to an influxdb1 server and, for the given databases or all
+// of them if empty, returns oldseries jobs suggested for the Telegraf layouts
+// found. It does not modify data
+func Discover(inf config.Influxdb1Info, dbs []string, lo *log.Logger) ([]DiscoveredDatabase, error) {
+	var found []DiscoveredDatabase
+
+	l = lo
+	l.AddSecrets(inf.Secrets()...)
+	var ic = &influxdb1.Influxdb1Client{Log: l}
+	if err := ic.Open(inf.Url, inf.User, inf.Password, inf.SkipsVerify(), true); err != nil {
+		return nil, fmt.Errorf("Could not connect to influxdb1 %s: %w", redact.URL(inf.Url), err)
+	}
+	defer ic.Close()
+
+	if len(dbs) == 0 {
+		var err error
+		if dbs, err = ic.QueryShowDatabases(); err != nil {
+			return nil, err
+		}
+	}
+	for _, db := range dbs {
+		if db == "_internal" {
+			continue
+		}
+		ms, err := ic.QueryShowMeasurements(db, "")
+		if err != nil {
+			return found, err
+		}
+		var dd = DiscoveredDatabase{Name: db, Measurements: len(ms)}
+		for _, lay := range telegrafLayouts {
+			if s, ok := lay.suggest(ic, db, ms); ok {
+				dd.Suggestions = append(dd.Suggestions, s)
+			}
+		}
+		found = append(found, dd)
+	}
+	return found, nil
+}
+
+// suggest returns the job suggested for the layout if any of the measurements
+// ms matches it, noting the layout tags missing in the first one matched
+func (lay layout) suggest(ic *influxdb1.Influxdb1Client, db string, ms []string) (Suggestion, bool) {
+	var s = Suggestion{Layout: lay.desc}
+	var p, _ = config.Preset(lay.preset)
+	switch len(p.Measurement_regex) {
+	case 0:
+		if sliceplus.Contains(ms, p.Measurement) {
+			s.Matched = []string{p.Measurement}
+		}
+	default:
+		var re = regexp.MustCompile(p.Measurement_regex)
+		for _, m := range ms {
+			if re.MatchString(m) {
+				s.Matched = append(s.Matched, m)
+			}
+		}
+	}
+	if len(s.Matched) == 0 {
+		return s, false
+	}
+
+	keys, err := ic.QueryShowTagKeys(db, "", s.Matched[0])
+	if err != nil {
+		s.Problems = append(s.Problems, fmt.Sprintf("could not list tag keys of %s: %v", s.Matched[0], err))
+	}
+	for _, tag := range p.Tags {
+		if err == nil && !sliceplus.Contains(keys, tag) {
+			s.Problems = append(s.Problems, fmt.Sprintf("tag %s not found in %s", tag, s.Matched[0]))
+		}
+	}
+
+	s.Job = config.OldSeriesInfo{
+		Name:           fmt.Sprintf("%s %s", db, lay.desc),
+		Preset:         lay.preset,
+		Dryrun:         config.DryrunAlways,
+		Databases:      []string{db},
+		Drop_from_all:  &lay.dropFromAll,
+		History_window: []string{"0m", "0m"},
+		Current_window: lay.current,
+	}
+	return s, true
+}
@@ -0,0 +1,172 @@
+// influxclean jobs package is responsible for launching queries and drops
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+package jobs
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/tesibelda/influxclean/datastore/influxdb1"
+	"github.com/tesibelda/influxclean/log"
+)
+
+// ErrorKind classifies the errors found running jobs
+type ErrorKind int
+
+const (
+	KindConnection ErrorKind = iota + 1 // could not connect to the server
+	KindAuth                            // the server rejected the credentials
+	KindQuery                           // a discovery query failed
+	KindDrop                            // a drop statement failed
+	KindAborted                         // a safety guard stopped the drops
+	KindAudit                           // a drop could not be recorded in the audit log
+)
+
+// String returns the error kind name
+func (k ErrorKind) String() string {
+	switch k {
+	case KindConnection:
+		return "connection"
+	case KindAuth:
+		return "auth"
+	case KindQuery:
+		return "query"
+	case KindDrop:
+		return "drop"
+	case KindAborted:
+		return "aborted"
+	case KindAudit:
+		return "audit"
+	}
+	return "unknown"
+}
+
+// JobError is an error found running a job with the place where it happened
+type JobError struct {
+	Kind   ErrorKind
+	Server string
+	Job    string
+	Db     string
+	Err    error
+}
+
+// Error returns the error prefixed by its kind and place
+func (e *JobError) Error() string {
+	var ctx = "influxdb1 " + e.Server
+	if len(e.Job) > 0 {
+		ctx = fmt.Sprintf("%s job %s", ctx, e.Job)
+	}
+	if len(e.Db) > 0 {
+		ctx = fmt.Sprintf("%s db %s", ctx, e.Db)
+	}
+	return fmt.Sprintf("%s: %s error: %v", ctx, e.Kind, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *JobError) Unwrap() error {
+	return e.Err
+}
+
+// fields returns the structured log fields of the error
+func (e *JobError) fields() log.Fields {
+	var fields = log.Fields{"server": e.Server, "kind": e.Kind.String()}
+	if len(e.Job) > 0 {
+		fields["job"] = e.Job
+	}
+	if len(e.Db) > 0 {
+		fields["db"] = e.Db
+	}
+	return fields
+}
+
+// classify returns the kind of err, which is kind unless the server rejected
+// the credentials with an HTTP status, the drops were not confirmed or they
+// could not be recorded in the audit log
+func classify(err error, kind ErrorKind) ErrorKind {
+	var ae *influxdb1.AuthError
+	var aue *influxdb1.AuditError
+	switch {
+	case errors.Is(err, ErrNotConfirmed):
+		return KindAborted
+	case errors.As(err, &ae):
+		return KindAuth
+	case errors.As(err, &aue):
+		return KindAudit
+	}
+	return kind
+}
+
+// Report summarizes a run of jobs with all the failures found
+type Report struct {
+	Jobs       int         // jobs run
+	Candidates int         // series found to drop
+	Dropped    int         // series dropped
+	Protected  int         // candidates spared by protect lists
+	LastSeen   []SeriesAge // last point of each candidate, in dry run only
+	Failures   []*JobError
+}
+
+// fail records a failure classified from err and kind and returns it
+func (r *Report) fail(kind ErrorKind, server, job, db string, err error) *JobError {
+	var je = &JobError{
+		Kind:   classify(err, kind),
+		Server: server,
+		Job:    job,
+		Db:     db,
+		Err:    err,
+	}
+	r.Failures = append(r.Failures, je)
+	return je
+}
+
+// Has reports whether the report has failures of the given kind
+func (r *Report) Has(kind ErrorKind) bool {
+	for _, f := range r.Failures {
+		if f.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Failed reports whether the report has failures other than aborted drops
+func (r *Report) Failed() bool {
+	for _, f := range r.Failures {
+		if f.Kind != KindAborted {
+			return true
+		}
+	}
+	return false
+}
+
+// Err returns an error listing all failures or nil if there are none
+func (r *Report) Err() error {
+	if len(r.Failures) == 0 {
+		return nil
+	}
+	return failures(r.Failures)
+}
+
+// failures is the error returned for a list of job errors
+type failures []*JobError
+
+// Error returns the number of failures and the first one
+func (f failures) Error() string {
+	if len(f) == 1 {
+		return f[0].Error()
+	}
+	return fmt.Sprintf("%d failures, first: %v", len(f), f[0])
+}
+
+// Is reports whether any of the failures matches target
+func (f failures) Is(target error) bool {
+	for _, je := range f {
+		if errors.Is(je, target) {
+			return true
+		}
+	}
+	return false
+}
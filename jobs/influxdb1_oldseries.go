@@ -6,193 +6,371 @@
 package jobs
 
 import (
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/tesibelda/influxclean/config"
 	"github.com/tesibelda/influxclean/datastore/influxdb1"
+	"github.com/tesibelda/influxclean/internal/redact"
 	"github.com/tesibelda/influxclean/internal/sliceplus"
+	"github.com/tesibelda/influxclean/internal/timewindow"
+	"github.com/tesibelda/influxclean/log"
 )
 
+// oldSeriesRun holds the state of an oldseries job run
+type oldSeriesRun struct {
+	ic     *influxdb1.Influxdb1Client
+	oc     config.OldSeriesInfo
+	prot   protector
+	qf, df string
+	fields map[string][]string
+	dryrun bool
+	rep    *Report
+	server string
+	log    *log.Logger
+}
+
 // runInfluxdb1OldSeries runs all oldseries jobs for influxdb1 databases
 func runInfluxdb1OldSeries(
 	ic *influxdb1.Influxdb1Client,
 	inf config.Influxdb1Info,
 	dryrun bool,
-) error {
-	var err, lasterr error
+	rep *Report,
+) {
+	var err error
+	var sl = ic.Log
+	var srv = redact.URL(inf.Url)
 	for _, job := range inf.Oldseries {
+		var jl = sl.With(log.Fields{"job": job.Name})
+		if !job.IsEnabled() {
+			jl.Infof("oldseries job %s is disabled, skipping it", job.Name)
+			continue
+		}
+		ic.Log = jl
 		if len(job.Databases) == 0 {
 			job.Databases, err = ic.QueryShowDatabases()
 			if err != nil {
-				l.Errorf("Error listing databases while runing oldseries job %s: %v",
+				jl.Errorf("Error listing databases while runing oldseries job %s: %v",
 					job.Name,
 					err,
 				)
+				rep.fail(KindQuery, srv, job.Name, "", err)
+				continue
 			}
 		}
-		l.Infof("oldseries job %s...", job.Name)
-		switch len(job.Tags) {
-		case 1:
-			err = runInfl1OldSeries1Dim(ic, job)
-		case 2:
-			err = runInfl1OldSeries2Dims(ic, job)
+		switch len(job.Source.File) {
+		case 0:
+			jl.Infof("oldseries job %s...", job.Name)
+		default:
+			jl.Infof("oldseries job %s from %s...", job.Name, job.Source)
+		}
+		var jobdry = dryrun || job.Dryrun == config.DryrunAlways
+		if jobdry && !dryrun {
+			jl.Infof("oldseries job %s runs with dry run always enabled", job.Name)
 		}
-		if err != nil {
-			l.Errorf("Error runing oldseries job %s: %v", job.Name, err)
-			lasterr = err
+		ic.SetDryrun(jobdry)
+		rep.Jobs++
+		var r = &oldSeriesRun{
+			ic:     ic,
+			oc:     job,
+			fields: map[string][]string{},
+			dryrun: jobdry,
+			rep:    rep,
+			server: srv,
+			log:    jl,
+		}
+		if n := len(rep.Failures); r.run() != n {
+			jl.Errorf("oldseries job %s had %d failures", job.Name, len(rep.Failures)-n)
 		}
 	}
-	return lasterr
+	ic.SetDryrun(dryrun)
+	ic.Log = sl
 }
 
-// runInfl1OldSeries1Dim runs all oldseries job of influxdb1 type and one tag dimension
-func runInfl1OldSeries1Dim(ic *influxdb1.Influxdb1Client, oc config.OldSeriesInfo) error {
+// run runs an oldseries job of influxdb1 type in all its databases, recording
+// its failures in the report, and returns the number of failures recorded
+func (r *oldSeriesRun) run() int {
 	var (
-		hdata, cdata, remdata []string
-		tag, m                string
-		hwb, hwe, cwb, cwe    string
-		sl                    time.Duration
-		err, lasterr          error
+		oc  = r.oc
+		ms  []string
+		sl  time.Duration
+		err error
 	)
 
-	tag = oc.Tags[0]
-	sl, _ = time.ParseDuration(oc.Sleep_duration)
-	hwb = oc.History_window[0]
-	hwe = oc.History_window[1]
-	cwb = oc.Current_window[0]
-	cwe = oc.Current_window[1]
+	sl, _ = timewindow.ParseDuration(oc.Sleep_duration)
+	if r.prot, err = newProtector(oc.Tags, oc.Protect); err != nil {
+		r.fail(KindAborted, "", err)
+		return len(r.rep.Failures)
+	}
+	r.qf, r.df = oldSeriesFilters(oc)
+	r.logWindow("History", oc.History_window)
+	r.logWindow("Current", oc.Current_window)
+	if oc.Field == "*" {
+		r.log.Infof("Using any field with data to detect current series")
+	}
 	for i, db := range oc.Databases {
 		if i > 0 {
 			time.Sleep(sl)
 		}
-		l.Infof("Working on database %s", db)
-		m = oc.Measurement
-		hdata, err = ic.Query1Dim(db, oc.Rp, m, oc.Field, tag, oc.Filter, hwb, hwe)
-		if err != nil {
-			lasterr = err
+		r.dbLog(db, "").Infof("Working on database %s", db)
+		if ms, err = r.measurements(db); err != nil {
+			r.fail(KindQuery, db, err)
 			continue
 		}
-		if len(hdata) == 0 {
-			l.Infof("No historic series found for oldseries job %s in %s db", oc.Name, db)
+		if len(ms) == 0 {
+			r.dbLog(db, "").Infof("No measurements found for oldseries job %s in %s db", oc.Name, db)
 			continue
 		}
-		cdata, err = ic.Query1Dim(db, oc.Rp, m, oc.Field, tag, oc.Filter, cwb, cwe)
-		if err != nil {
-			lasterr = err
+		if ms = r.preflight(db, ms); len(ms) == 0 {
 			continue
 		}
-		remdata = sliceplus.Difference(hdata, cdata)
-		switch len(remdata) {
-		case 0:
-			l.Infof("No series where found to drop from %s db", db)
-		default:
-			var about = "About to drop series from"
-			switch oc.Drop_from_all {
-			case true:
-				l.Infof("%s %s db for tag %s with %d values",
-					about,
-					db,
-					tag,
-					len(remdata),
-				)
-			default:
-				l.Infof("%s measurement %s in %s db for tag %s with %d values",
-					about,
-					m,
-					db,
-					tag,
-					len(remdata),
-				)
-			}
+		if oc.DropsFromAll() {
+			r.runTarget(db, ms, "")
+			continue
 		}
-		for _, ch := range sliceplus.ChunkSlice(remdata, 60) {
-			if oc.Drop_from_all {
-				m = ""
-			}
-			if err = ic.DropSeries1Dim(db, m, tag, ch); err != nil {
-				lasterr = err
-				continue
-			}
+		for _, m := range ms {
+			r.runTarget(db, []string{m}, m)
 		}
 	}
-	return lasterr
+	return len(r.rep.Failures)
 }
 
-// runInfl1OldSeries2Dims runs all oldseries job of influxdb1 type and two tag dimensions
-func runInfl1OldSeries2Dims(ic *influxdb1.Influxdb1Client, oc config.OldSeriesInfo) error {
+// fail records a failure of the job in database db
+func (r *oldSeriesRun) fail(kind ErrorKind, db string, err error) {
+	var je = r.rep.fail(kind, r.server, r.oc.Name, db, err)
+	r.dbLog(db, "").With(log.Fields{"kind": je.Kind.String()}).Errorf("Error runing oldseries job %s: %v",
+		r.oc.Name,
+		je.Err,
+	)
+}
+
+// dbLog returns the job logger with the database and, if not empty, the
+// measurement fields
+func (r *oldSeriesRun) dbLog(db, m string) *log.Logger {
+	var fields = log.Fields{}
+	if len(db) > 0 {
+		fields["db"] = db
+	}
+	if len(m) > 0 {
+		fields["measurement"] = m
+	}
+	return r.log.With(fields)
+}
+
+// runTarget drops series of the job tags with historic data but no current data
+// in any of the measurements ms, dropping them from measurement dropm or from all
+// measurements if dropm is empty. Failures are recorded in the report
+func (r *oldSeriesRun) runTarget(db string, ms []string, dropm string) {
 	var (
-		hdata, cdata, remdata []string
-		vals1, vals2          []string
-		tag1, tag2, m         string
-		hwb, hwe, cwb, cwe    string
-		sl                    time.Duration
-		err, lasterr          error
+		hdata, cdata, data []string
+		remdata, protdata  []string
+		err                error
+		tl                 = r.dbLog(db, dropm)
 	)
 
-	tag1 = oc.Tags[0]
-	tag2 = oc.Tags[1]
-	sl, _ = time.ParseDuration(oc.Sleep_duration)
-	hwb = oc.History_window[0]
-	hwe = oc.History_window[1]
-	cwb = oc.Current_window[0]
-	cwe = oc.Current_window[1]
-	for i, db := range oc.Databases {
-		if i > 0 {
-			time.Sleep(sl)
+	for _, m := range ms {
+		if data, err = r.query(db, m, r.oc.History_window); err != nil {
+			r.fail(KindQuery, db, err)
+			return
 		}
-		l.Infof("Working on database %s", db)
-		m = oc.Measurement
-		hdata, err = ic.Query2Dims(db, oc.Rp, m, oc.Field, tag1, tag2, oc.Filter, hwb, hwe)
-		if err != nil {
-			lasterr = err
-			continue
+		hdata = sliceplus.Union(hdata, data)
+	}
+	if len(hdata) == 0 {
+		tl.Infof("No historic series found for oldseries job %s in %s db", r.oc.Name, db)
+		return
+	}
+	for _, m := range ms {
+		if data, err = r.query(db, m, r.oc.Current_window); err != nil {
+			r.fail(KindQuery, db, err)
+			return
 		}
-		if len(hdata) == 0 {
-			l.Infof("No historic series found for oldseries job %s in %s db", oc.Name, db)
-			continue
+		cdata = sliceplus.Union(cdata, data)
+	}
+	remdata = sliceplus.Difference(hdata, cdata)
+	remdata, protdata = r.prot.split(remdata)
+	r.rep.Candidates += len(remdata)
+	r.rep.Protected += len(protdata)
+	if len(protdata) > 0 {
+		tl.Infof("Protected %d series from drop in %s db: %s",
+			len(protdata),
+			db,
+			strings.Join(protdata, ", "),
+		)
+	}
+
+	var tagdesc = "tag " + r.oc.Tags[0]
+	if len(r.oc.Tags) == 2 {
+		tagdesc = fmt.Sprintf("tags %s and %s", r.oc.Tags[0], r.oc.Tags[1])
+	}
+	switch len(remdata) {
+	case 0:
+		tl.Infof("No series where found to drop from %s db", db)
+	default:
+		var about = "About to drop series from"
+		switch len(dropm) {
+		case 0:
+			tl.Infof("%s %s db for %s with %d values", about, db, tagdesc, len(remdata))
+		default:
+			tl.Infof("%s measurement %s in %s db for %s with %d values",
+				about,
+				dropm,
+				db,
+				tagdesc,
+				len(remdata),
+			)
+		}
+	}
+
+	if r.dryrun && len(remdata) > 0 {
+		ls, err := r.queryLastSeen(db, ms, remdata)
+		switch err {
+		case nil:
+			logLastSeen(tl, ls, r.oc.Tags)
+			r.rep.LastSeen = append(r.rep.LastSeen, ls...)
+		default:
+			tl.Warnf("Could not query last seen times in %s db: %v", db, err)
+		}
+	}
+
+	if !r.dryrun && len(remdata) > 0 && confirmDrop != nil {
+		if !confirmDrop(r.oc.Name, db, dropm, r.oc.Tags, remdata) {
+			tl.Warnf("Drops for oldseries job %s in %s db were not confirmed, skipping them",
+				r.oc.Name,
+				db,
+			)
+			r.rep.fail(KindAborted, r.server, r.oc.Name, db, ErrNotConfirmed)
+			return
+		}
+	}
+
+	var chunk = 60
+	if len(r.oc.Tags) == 2 {
+		chunk = 40
+	}
+	for _, ch := range sliceplus.ChunkSlice(remdata, chunk) {
+		var ae *influxdb1.AuditError
+		err = r.drop(db, dropm, ch)
+		if !r.dryrun && (err == nil || errors.As(err, &ae) && ae.Dropped) {
+			r.rep.Dropped += len(ch)
 		}
-		cdata, err = ic.Query2Dims(db, oc.Rp, m, oc.Field, tag1, tag2, oc.Filter, cwb, cwe)
 		if err != nil {
-			lasterr = err
-			continue
+			r.fail(KindDrop, db, err)
 		}
-		remdata = sliceplus.Difference(hdata, cdata)
-		switch len(remdata) {
-		case 0:
-			l.Infof("No series where found to drop from %s db", db)
-		default:
-			var about = "About to drop series from"
-			switch oc.Drop_from_all {
-			case true:
-				l.Infof("%s %s db for tags %s and %s with %d values",
-					about,
-					db,
-					tag1,
-					tag2,
-					len(remdata),
-				)
-			default:
-				l.Infof("%s measurement %s in %s db for tags %s and %s with %d values",
-					about,
-					m,
-					db,
-					tag1,
-					tag2,
-					len(remdata),
-				)
-			}
+	}
+}
+
+// query returns the job tag values with data in measurement m and time window w
+func (r *oldSeriesRun) query(db, m string, w []string) ([]string, error) {
+	var oc = r.oc
+	var fields, err = r.dataFields(db, m)
+	if err != nil {
+		return nil, err
+	}
+	switch len(oc.Tags) {
+	case 1:
+		return r.ic.Query1Dim(db, oc.Rp, m, fields, oc.Tags[0], r.qf, w[0], w[1])
+	default:
+		return r.ic.Query2Dims(db, oc.Rp, m, fields, oc.Tags[0], oc.Tags[1], r.qf, w[0], w[1])
+	}
+}
+
+// drop drops the given series of the job tags from measurement m
+func (r *oldSeriesRun) drop(db, m string, series []string) error {
+	var tags = r.oc.Tags
+	switch len(tags) {
+	case 1:
+		return r.ic.DropSeries1Dim(db, m, tags[0], series, r.df)
+	default:
+		var vals1, vals2 = sliceplus.Split2Dims(series, influxdb1.Separator)
+		return r.ic.DropSeries2Dims(db, m, tags[0], vals1, tags[1], vals2, r.df)
+	}
+}
+
+// measurements returns the names of the measurements the job works on in the
+// database, unquoted as the query builders quote them
+func (r *oldSeriesRun) measurements(db string) ([]string, error) {
+	var ms []string
+	if len(r.oc.Measurement) > 0 {
+		ms = append(ms, influxdb1.Unquote(r.oc.Measurement))
+	}
+	for _, m := range r.oc.Measurements {
+		ms = sliceplus.Union(ms, []string{influxdb1.Unquote(m)})
+	}
+	if len(r.oc.Measurement_regex) > 0 {
+		var matched, err = r.ic.QueryShowMeasurements(db, r.oc.Measurement_regex)
+		if err != nil {
+			return nil, err
 		}
+		ms = sliceplus.Union(ms, matched)
+	}
+	return ms, nil
+}
 
-		for _, ch := range sliceplus.ChunkSlice(remdata, 40) {
-			if oc.Drop_from_all {
-				m = ""
-			}
-			vals1, vals2 = sliceplus.Split2Dims(ch, influxdb1.Separator)
-			if err = ic.DropSeries2Dims(db, m, tag1, vals1, tag2, vals2); err != nil {
-				lasterr = err
-				continue
-			}
+// dataFields returns the fields used to detect data in measurement m, which is
+// the one in the job fields map or the job field. If it is empty or "auto" the
+// first field key of the measurement is used, and "*" means all its field keys
+func (r *oldSeriesRun) dataFields(db, m string) ([]string, error) {
+	if fields, ok := r.fields[db+"."+m]; ok {
+		return fields, nil
+	}
+	var field = r.oc.Field
+	if f, ok := r.oc.Fields[m]; ok && len(f) > 0 {
+		field = f
+	}
+	switch field {
+	case "", "auto":
+		var keys, err = r.ic.QueryShowFieldKeys(db, r.oc.Rp, m)
+		if err != nil {
+			return nil, err
+		}
+		if len(keys) == 0 {
+			return nil, fmt.Errorf("no field keys found for measurement %s in %s db", m, db)
 		}
+		r.dbLog(db, m).Infof("Using field %s for measurement %s in %s db", keys[0], m, db)
+		field = influxdb1.QuoteIdent(keys[0])
+	}
+	var fields, err = r.ic.ExpandField(db, r.oc.Rp, m, field)
+	if err != nil {
+		return nil, err
+	}
+	r.fields[db+"."+m] = fields
+	return fields, nil
+}
+
+// logWindow logs the absolute time range a relative or absolute window resolves to
+func (r *oldSeriesRun) logWindow(desc string, w []string) {
+	var now = time.Now()
+	b, _ := timewindow.ParseBound(w[0])
+	e, _ := timewindow.ParseBound(w[1])
+	if b.IsNow() && e.IsNow() {
+		r.log.Infof("%s window without time restriction", desc)
+		return
+	}
+	r.log.Infof("%s window from %s to %s",
+		desc,
+		b.Time(now).UTC().Format(time.RFC3339),
+		e.Time(now).UTC().Format(time.RFC3339),
+	)
+}
+
+// oldSeriesFilters returns the filter expression for discovery queries, which
+// combines the legacy filter with structured include/exclude entries, and the
+// filter expression for drops, which only includes structured entries
+func oldSeriesFilters(oc config.OldSeriesInfo) (string, string) {
+	var conds []influxdb1.Condition
+	for _, f := range oc.Include {
+		conds = append(conds, influxdb1.Condition{
+			Tag:      f.Tag,
+			Operator: f.Operator,
+			Value:    f.Value,
+		})
+	}
+	for _, f := range oc.Exclude {
+		var c = influxdb1.Condition{Tag: f.Tag, Operator: f.Operator, Value: f.Value}
+		conds = append(conds, c.Negate())
 	}
-	return lasterr
+	var structured = influxdb1.RenderConditions(conds)
+	return influxdb1.JoinFilters(oc.Filter, structured), structured
 }
@@ -0,0 +1,149 @@
+// influxclean jobs package is responsible for launching queries and drops
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+package jobs
+
+import (
+	"fmt"
+
+	"github.com/tesibelda/influxclean/config"
+	"github.com/tesibelda/influxclean/datastore/influxdb1"
+	"github.com/tesibelda/influxclean/internal/redact"
+	"github.com/tesibelda/influxclean/internal/sliceplus"
+	"github.com/tesibelda/influxclean/log"
+)
+
+// InspectOptions selects what Inspect analyzes
+type InspectOptions struct {
+	Databases        []string // databases to inspect, all if empty
+	Rp               string   // retention policy, default if empty
+	Measurements     []string // measurements to inspect, all if empty and no regex
+	MeasurementRegex string   // regular expression matching measurements to inspect
+	Tags             []string // tag keys whose values are checked for staleness
+	Field            string   // field with data to detect current values, any if empty
+	Window           []string // window in which values with data are current
+}
+
+// TagStats is the cardinality of a tag key in a measurement
+type TagStats struct {
+	Key     string
+	Values  int64 // values in the index
+	Checked int   // values checked for staleness, 0 if not checked
+	Stale   int   // checked values with no data in the window
+}
+
+// MeasurementStats is the cardinality of a measurement and its tag keys
+type MeasurementStats struct {
+	Name   string
+	Series int64
+	Tags   []TagStats
+}
+
+// DatabaseStats is the cardinality of the measurements of a database
+type DatabaseStats struct {
+	Name         string
+	Measurements []MeasurementStats
+}
+
+// Inspect connects to an influxdb1 server and returns the series cardinality
+// of the selected measurements and their tag keys, and for the chosen tag keys
+// the number of values with no data in the window. It does not modify data
+func Inspect(inf config.Influxdb1Info, o InspectOptions, lo *log.Logger) ([]DatabaseStats, error) {
+	var stats []DatabaseStats
+
+	l = lo
+	l.AddSecrets(inf.Secrets()...)
+	var ic = &influxdb1.Influxdb1Client{Log: l}
+	if err := ic.Open(inf.Url, inf.User, inf.Password, inf.SkipsVerify(), true); err != nil {
+		return nil, fmt.Errorf("Could not connect to influxdb1 %s: %w", redact.URL(inf.Url), err)
+	}
+	defer ic.Close()
+
+	var dbs = o.Databases
+	if len(dbs) == 0 {
+		var err error
+		if dbs, err = ic.QueryShowDatabases(); err != nil {
+			return nil, err
+		}
+	}
+	if len(o.Field) == 0 {
+		o.Field = "*"
+	}
+	for _, db := range dbs {
+		var ds = DatabaseStats{Name: db}
+		var ms, err = inspectMeasurements(ic, db, o)
+		if err != nil {
+			return stats, err
+		}
+		for _, m := range ms {
+			ds.Measurements = append(ds.Measurements, inspectMeasurement(ic, db, m, o))
+		}
+		stats = append(stats, ds)
+	}
+	return stats, nil
+}
+
+// inspectMeasurements returns the measurements to inspect in a database
+func inspectMeasurements(ic *influxdb1.Influxdb1Client, db string, o InspectOptions) ([]string, error) {
+	for i, m := range o.Measurements {
+		o.Measurements[i] = influxdb1.Unquote(m)
+	}
+	if len(o.Measurements) > 0 && len(o.MeasurementRegex) == 0 {
+		return o.Measurements, nil
+	}
+	var matched, err = ic.QueryShowMeasurements(db, o.MeasurementRegex)
+	if err != nil {
+		return nil, err
+	}
+	return sliceplus.Union(o.Measurements, matched), nil
+}
+
+// inspectMeasurement returns the cardinality of a measurement, warning about
+// the queries that failed
+func inspectMeasurement(ic *influxdb1.Influxdb1Client, db, m string, o InspectOptions) MeasurementStats {
+	var ms = MeasurementStats{Name: m}
+	var err error
+
+	if ms.Series, err = ic.QuerySeriesCardinality(db, o.Rp, m); err != nil {
+		l.Warnf("Could not count series of measurement %s in %s db: %v", m, db, err)
+	}
+	keys, err := ic.QueryShowTagKeys(db, o.Rp, m)
+	if err != nil {
+		l.Warnf("Could not list tag keys of measurement %s in %s db: %v", m, db, err)
+		return ms
+	}
+	for _, key := range keys {
+		var ts = TagStats{Key: key}
+		if ts.Values, err = ic.QueryTagValuesCardinality(db, o.Rp, m, key); err != nil {
+			l.Warnf("Could not count values of tag %s in measurement %s: %v", key, m, err)
+		}
+		if sliceplus.Contains(o.Tags, key) {
+			ts.Checked, ts.Stale, err = staleValues(ic, db, m, key, o)
+			if err != nil {
+				l.Warnf("Could not check stale values of tag %s in measurement %s: %v", key, m, err)
+			}
+		}
+		ms.Tags = append(ms.Tags, ts)
+	}
+	return ms
+}
+
+// staleValues returns the number of values of a tag key in the index and how
+// many of them have no data in the window
+func staleValues(ic *influxdb1.Influxdb1Client, db, m, key string, o InspectOptions) (int, int, error) {
+	var all, err = ic.QueryShowTagValues(db, o.Rp, m, key, "")
+	if err != nil {
+		return 0, 0, err
+	}
+	fields, err := ic.ExpandField(db, o.Rp, m, o.Field)
+	if err != nil {
+		return 0, 0, err
+	}
+	current, err := ic.Query1Dim(db, o.Rp, m, fields, key, "", o.Window[0], o.Window[1])
+	if err != nil {
+		return 0, 0, err
+	}
+	return len(all), len(sliceplus.Difference(all, current)), nil
+}
@@ -6,52 +6,112 @@
 package jobs
 
 import (
+	"errors"
+
+	"github.com/tesibelda/influxclean/audit"
+	"github.com/tesibelda/influxclean/backup"
 	"github.com/tesibelda/influxclean/config"
 	"github.com/tesibelda/influxclean/datastore/influxdb1"
+	"github.com/tesibelda/influxclean/internal/redact"
 	"github.com/tesibelda/influxclean/log"
 )
 
 var l *log.Logger
 
-// RunJobs runs cleanup jobs defined in the provided configuration
-func RunJobs(cfg *config.InfluxCleanConfig, lo *log.Logger, dryrun bool) error {
-	var err error
+// Confirmer is asked before dropping the candidate series of a job in
+// measurement m of a database, or in all its measurements if m is empty, and
+// reports whether the drops may proceed
+type Confirmer func(job, db, m string, tags, series []string) bool
+
+var confirmDrop Confirmer
+
+// ErrNotConfirmed is returned when drops were skipped for lack of confirmation
+var ErrNotConfirmed = errors.New("drops not confirmed")
+
+// SetConfirmer sets the function asked before dropping series when dry run is
+// disabled, nil to drop without confirmation
+func SetConfirmer(c Confirmer) {
+	confirmDrop = c
+}
+
+var auditLog *audit.Log
+
+// SetAuditLog sets the audit log where drop statements are recorded, nil to
+// not record them
+func SetAuditLog(a *audit.Log) {
+	auditLog = a
+}
+
+var backupFile *backup.Writer
+
+// SetBackup sets the backup file where the points of series are saved before
+// dropping them, nil to drop without saving them
+func SetBackup(b *backup.Writer) {
+	backupFile = b
+}
+
+// RunJobs runs cleanup jobs defined in the provided configuration and returns
+// a report with the series dropped and all the failures found, and an error
+// listing them if any
+func RunJobs(cfg *config.InfluxCleanConfig, lo *log.Logger, dryrun bool) (*Report, error) {
+	var rep = &Report{}
 
 	l = lo
+	l.AddSecrets(cfg.Secrets()...)
 	if cfg.Influxdb1 != nil {
-		err = runInfluxdb1Jobs(cfg, dryrun)
+		runInfluxdb1Jobs(cfg, dryrun, rep)
 	}
 	// here more job and db types in the future (influxdb2, emptydbs,...)
-	if err == nil {
-		l.Info("Jobs completed")
+	var rl = l.With(log.Fields{
+		"candidates": rep.Candidates,
+		"dropped":    rep.Dropped,
+		"protected":  rep.Protected,
+		"failures":   len(rep.Failures),
+	})
+	switch len(rep.Failures) {
+	case 0:
+		rl.Infof("Jobs completed, %d series found to drop, %d dropped, %d protected",
+			rep.Candidates,
+			rep.Dropped,
+			rep.Protected,
+		)
+	default:
+		rl.Errorf("Jobs completed with %d failures, %d series found to drop, %d dropped, %d protected:",
+			len(rep.Failures),
+			rep.Candidates,
+			rep.Dropped,
+			rep.Protected,
+		)
+		for _, f := range rep.Failures {
+			l.With(f.fields()).Errorf("  %v", f)
+		}
 	}
-	return err
+	return rep, rep.Err()
 }
 
 // runInfluxdb1Jobs runs all jobs of influxdb1 database type
-func runInfluxdb1Jobs(cfg *config.InfluxCleanConfig, dryrun bool) error {
+func runInfluxdb1Jobs(cfg *config.InfluxCleanConfig, dryrun bool, rep *Report) {
 	var drywarn string
-	var err, worsterr error
-	var ic = &influxdb1.Influxdb1Client{Log: l}
+	var ic = &influxdb1.Influxdb1Client{Audit: auditLog, Backup: backupFile}
 	for _, inf := range cfg.Influxdb1 {
+		var srv = redact.URL(inf.Url)
+		var sl = l.With(log.Fields{"server": srv})
+		ic.Log = sl
 		switch dryrun {
 		case true:
 			drywarn = "with dry run enabled"
 		default:
 			drywarn = "with dry run DISABLED"
 		}
-		l.Infof("Connecting to influxdb1 at %s %s", inf.Url, drywarn)
-		err = ic.Open(inf.Url, inf.User, inf.Password, inf.Insecure_skip_verify, dryrun)
+		sl.Infof("Connecting to influxdb1 at %s %s", srv, drywarn)
+		var err = ic.Open(inf.Url, inf.User, inf.Password, inf.SkipsVerify(), dryrun)
 		if err != nil {
-			l.Errorf("Could not connect to influxdb1 %s: %v", inf.Url, err)
-			worsterr = err
+			sl.Errorf("Could not connect to influxdb1 %s: %v", srv, err)
+			rep.fail(KindConnection, srv, "", "", err)
 			continue
 		}
-		if err = runInfluxdb1OldSeries(ic, inf, dryrun); err != nil {
-			worsterr = err
-		}
+		runInfluxdb1OldSeries(ic, inf, dryrun, rep)
 		// here more job types in the future (emptydbs,...)
 		ic.Close()
 	}
-	return worsterr
 }
@@ -0,0 +1,76 @@
+// influxclean jobs package is responsible for launching queries and drops
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+package jobs
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tesibelda/influxclean/config"
+	"github.com/tesibelda/influxclean/datastore/influxdb1"
+	"github.com/tesibelda/influxclean/log"
+)
+
+// fakeServer answers pings and the queries starting with each key of responses
+// with its JSON value, recording the queries received
+type fakeServer struct {
+	responses map[string]string
+	queries   []string
+}
+
+func (fs *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/ping" {
+		w.Header().Set("X-Influxdb-Version", "1.8.10")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	var q = r.FormValue("q")
+	fs.queries = append(fs.queries, q)
+	w.Header().Set("Content-Type", "application/json")
+	for prefix, body := range fs.responses {
+		if strings.HasPrefix(q, prefix) {
+			w.Write([]byte(body))
+			return
+		}
+	}
+	w.Write([]byte(`{"results":[{"statement_id":0}]}`))
+}
+
+// newFakeRun returns an oldseries run of job connected to a fake server
+func newFakeRun(t *testing.T, job config.OldSeriesInfo, responses map[string]string) (*oldSeriesRun, *fakeServer) {
+	var fs = &fakeServer{responses: responses}
+	var srv = httptest.NewServer(fs)
+	t.Cleanup(srv.Close)
+	var lg = log.NewLogger(false)
+	var ic = &influxdb1.Influxdb1Client{Log: lg}
+	if err := ic.Open(srv.URL, "", "", false, true); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(ic.Close)
+	fs.queries = nil
+	var r = &oldSeriesRun{
+		ic:     ic,
+		oc:     job,
+		fields: map[string][]string{},
+		dryrun: true,
+		rep:    &Report{},
+		server: srv.URL,
+		log:    lg,
+	}
+	return r, fs
+}
+
+// showResponse returns the JSON response of a SHOW query with one column
+func showResponse(column string, values ...string) string {
+	var rows = make([]string, 0, len(values))
+	for _, v := range values {
+		rows = append(rows, `["`+v+`"]`)
+	}
+	return `{"results":[{"statement_id":0,"series":[{"columns":["` + column + `"],"values":[` +
+		strings.Join(rows, ",") + `]}]}]}`
+}
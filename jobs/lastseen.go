@@ -0,0 +1,91 @@
+// influxclean jobs package is responsible for launching queries and drops
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+package jobs
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/tesibelda/influxclean/datastore/influxdb1"
+	"github.com/tesibelda/influxclean/log"
+)
+
+// SeriesAge is the time of the last point received for a candidate series of a
+// job, zero if unknown
+type SeriesAge struct {
+	Server string
+	Job    string
+	Db     string
+	Tags   []string  // tag keys of the series
+	Series string    // tag values joined with influxdb1.Separator
+	Time   time.Time // last point in any of the job measurements
+}
+
+// queryLastSeen returns the last seen time of each candidate series in any
+// of the measurements ms, sorted oldest first with unknown times at the top
+func (r *oldSeriesRun) queryLastSeen(db string, ms, series []string) ([]SeriesAge, error) {
+	var seen = make(map[string]time.Time, len(series))
+	for _, m := range ms {
+		fields, err := r.dataFields(db, m)
+		if err != nil {
+			return nil, err
+		}
+		mseen, err := r.ic.QueryLastSeen(db, r.oc.Rp, m, fields, r.oc.Tags, r.qf)
+		if err != nil {
+			return nil, err
+		}
+		for s, t := range mseen {
+			if t.After(seen[s]) {
+				seen[s] = t
+			}
+		}
+	}
+
+	var ls = make([]SeriesAge, 0, len(series))
+	for _, s := range series {
+		ls = append(ls, SeriesAge{
+			Server: r.server,
+			Job:    r.oc.Name,
+			Db:     db,
+			Tags:   r.oc.Tags,
+			Series: s,
+			Time:   seen[s],
+		})
+	}
+	sort.SliceStable(ls, func(i, j int) bool {
+		return ls[i].Time.Before(ls[j].Time)
+	})
+	return ls, nil
+}
+
+// logLastSeen logs a table with the last seen time and age of each candidate
+func logLastSeen(tl *log.Logger, ls []SeriesAge, tags []string) {
+	var buf bytes.Buffer
+	var now = time.Now()
+	var w = tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(w, "LAST SEEN\tAGE\t%s\n", strings.ToUpper(strings.Join(tags, "\t")))
+	for _, s := range ls {
+		var seen, age = "unknown", "unknown"
+		if !s.Time.IsZero() {
+			seen = s.Time.UTC().Format(time.RFC3339)
+			age = now.Sub(s.Time).Truncate(time.Second).String()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n",
+			seen,
+			age,
+			strings.ReplaceAll(s.Series, influxdb1.Separator, "\t"),
+		)
+	}
+	w.Flush()
+	for _, line := range strings.Split(strings.TrimRight(buf.String(), "\n"), "\n") {
+		tl.Info(line)
+	}
+}
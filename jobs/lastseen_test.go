@@ -0,0 +1,52 @@
+// influxclean jobs package is responsible for launching queries and drops
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+package jobs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tesibelda/influxclean/config"
+)
+
+func TestRunReportsLastSeen(t *testing.T) {
+	var job = config.OldSeriesInfo{
+		Name:           "stale",
+		Databases:      []string{"telegraf"},
+		Measurement:    "cpu",
+		Field:          "value",
+		Tags:           []string{"host"},
+		History_window: []string{"0m", "0m"},
+		Current_window: []string{"72h", "1m"},
+	}
+	var r, _ = newFakeRun(t, job, map[string]string{
+		"SHOW MEASUREMENTS": showResponse("name", "cpu"),
+		"SHOW FIELD KEYS":   showResponse("fieldKey", "value"),
+		"SHOW TAG KEYS":     showResponse("tagKey", "host"),
+		"SHOW TAG VALUES":   showResponse("value", "h1", "h2"),
+		"SELECT last": `{"results":[{"statement_id":0,"series":[` +
+			`{"name":"cpu","tags":{"host":"h2"},"columns":["time","last"],"values":[["2024-01-01T00:00:00Z",1]]}]}]}`,
+	})
+	if n := r.run(); n > 0 {
+		t.Fatalf("run() failures = %v", r.rep.Failures)
+	}
+	var want = []SeriesAge{
+		{Job: "stale", Db: "telegraf", Series: "h1"},
+		{Job: "stale", Db: "telegraf", Series: "h2", Time: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	if len(r.rep.LastSeen) != len(want) {
+		t.Fatalf("LastSeen = %v, want %v", r.rep.LastSeen, want)
+	}
+	for i, w := range want {
+		var got = r.rep.LastSeen[i]
+		if got.Job != w.Job || got.Db != w.Db || got.Series != w.Series || !got.Time.Equal(w.Time) {
+			t.Errorf("LastSeen[%d] = %+v, want %+v", i, got, w)
+		}
+		if got.Server != r.server || len(got.Tags) != 1 || got.Tags[0] != "host" {
+			t.Errorf("LastSeen[%d] server %s tags %v, want %s [host]", i, got.Server, got.Tags, r.server)
+		}
+	}
+}
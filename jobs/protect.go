@@ -0,0 +1,52 @@
+// influxclean jobs package is responsible for launching queries and drops
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+package jobs
+
+import (
+	"strings"
+
+	"github.com/tesibelda/influxclean/datastore/influxdb1"
+	"github.com/tesibelda/influxclean/internal/pattern"
+)
+
+// protector holds the compiled protect patterns of a job by tag position
+type protector [][]*pattern.Pattern
+
+// newProtector compiles the protect lists that apply to the given job tags
+func newProtector(tags []string, prot map[string][]string) (protector, error) {
+	var err error
+	var p = make(protector, len(tags))
+	for i, tag := range tags {
+		if p[i], err = pattern.CompileList(prot[tag]); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// split returns the series that may be dropped and the protected ones, series
+// being values of the job tags joined with influxdb1.Separator
+func (p protector) split(series []string) ([]string, []string) {
+	var drop, protected []string
+	for _, s := range series {
+		if p.protects(strings.Split(s, influxdb1.Separator)) {
+			protected = append(protected, s)
+			continue
+		}
+		drop = append(drop, s)
+	}
+	return drop, protected
+}
+
+// protects reports whether any of the tag values matches its protect list
+func (p protector) protects(vals []string) bool {
+	for i, v := range vals {
+		if i < len(p) && pattern.MatchAny(p[i], v) {
+			return true
+		}
+	}
+	return false
+}
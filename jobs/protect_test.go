@@ -0,0 +1,43 @@
+// influxclean jobs package is responsible for launching queries and drops
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+package jobs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestProtectorSplit(t *testing.T) {
+	var p, err = newProtector([]string{"namespace", "pod_name"}, map[string][]string{
+		"namespace": {"kube-system"},
+		"pod_name":  {"etcd-*", "/^coredns-/"},
+		"host":      {"node01"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var series = []string{
+		"kube-system#metrics-server-1",
+		"default#etcd-0",
+		"monitoring#coredns-abc",
+		"default#web-1",
+		"node01#web-2",
+		"default#kube-system",
+	}
+	var drop, protected = p.split(series)
+	var wantDrop = []string{"default#web-1", "node01#web-2", "default#kube-system"}
+	var wantProtected = []string{"kube-system#metrics-server-1", "default#etcd-0", "monitoring#coredns-abc"}
+	if !reflect.DeepEqual(drop, wantDrop) {
+		t.Errorf("split() drop = %q, want %q", drop, wantDrop)
+	}
+	if !reflect.DeepEqual(protected, wantProtected) {
+		t.Errorf("split() protected = %q, want %q", protected, wantProtected)
+	}
+
+	if _, err = newProtector([]string{"host"}, map[string][]string{"host": {"db["}}); err == nil {
+		t.Errorf("newProtector() with invalid glob succeeded")
+	}
+}
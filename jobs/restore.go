@@ -0,0 +1,60 @@
+// influxclean jobs package is responsible for launching queries and drops
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+package jobs
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/tesibelda/influxclean/backup"
+	"github.com/tesibelda/influxclean/config"
+	"github.com/tesibelda/influxclean/datastore/influxdb1"
+	"github.com/tesibelda/influxclean/internal/redact"
+	"github.com/tesibelda/influxclean/log"
+)
+
+// restoreBatch is the number of points written to the server at once
+const restoreBatch = 5000
+
+// Restore connects to an influxdb1 server and writes back the points saved in
+// backup files before drops, or only reads them if dryrun is set, returning the
+// number of points written or read
+func Restore(inf config.Influxdb1Info, files []string, lo *log.Logger, dryrun bool) (int, error) {
+	var n int
+
+	l = lo
+	l.AddSecrets(inf.Secrets()...)
+	var ic = &influxdb1.Influxdb1Client{Log: l}
+	if err := ic.Open(inf.Url, inf.User, inf.Password, inf.SkipsVerify(), dryrun); err != nil {
+		return 0, fmt.Errorf("Could not connect to influxdb1 %s: %w", redact.URL(inf.Url), err)
+	}
+	defer ic.Close()
+
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return n, err
+		}
+		var fn int
+		err = backup.Read(f, restoreBatch, func(db, rp string, lines []string) error {
+			var written, err = ic.WriteLines(db, rp, lines)
+			fn += written
+			return err
+		})
+		f.Close()
+		n += fn
+		if err != nil {
+			return n, fmt.Errorf("Restoring %s failed after %d points: %w", file, fn, err)
+		}
+		switch dryrun {
+		case true:
+			l.Infof("Read %d points from %s, dry run enabled so none was written", fn, file)
+		default:
+			l.Infof("Restored %d points from %s", fn, file)
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,127 @@
+// influxclean jobs package is responsible for launching queries and drops
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+package jobs
+
+import (
+	"fmt"
+
+	"github.com/tesibelda/influxclean/internal/sliceplus"
+)
+
+// schemaProblem is a problem found checking the job schema in a database, of
+// measurement m or of the whole database if m is empty
+type schemaProblem struct {
+	m   string
+	err error
+}
+
+// preflight checks the job schema in the database before running discovery
+// queries and returns the measurements the job may run on. Measurements with
+// problems are skipped with a warning, and failed checks or problems skipping
+// the whole database are recorded in the report
+func (r *oldSeriesRun) preflight(db string, ms []string) []string {
+	var problems, err = r.schemaProblems(db, ms)
+	if err != nil {
+		r.fail(KindQuery, db, fmt.Errorf("schema check failed: %w", err))
+		return nil
+	}
+	var skip = make(map[string]bool)
+	for _, p := range problems {
+		if len(p.m) == 0 {
+			r.fail(KindAborted, db, fmt.Errorf("skipping %s db: %w", db, p.err))
+			return nil
+		}
+		skip[p.m] = true
+		r.dbLog(db, p.m).Warnf("Skipping measurement %s in %s db for oldseries job %s: %v",
+			p.m,
+			db,
+			r.oc.Name,
+			p.err,
+		)
+	}
+	var valid []string
+	for _, m := range ms {
+		if !skip[m] {
+			valid = append(valid, m)
+		}
+	}
+	switch {
+	case len(valid) == 0:
+		r.fail(KindAborted, db, fmt.Errorf("skipping %s db: %w", db, problems[0].err))
+	case len(skip) > 0 && r.oc.DropsFromAll():
+		r.dbLog(db, "").Warnf("Current series in %s db are only searched in the %d measurements passing the schema check",
+			db,
+			len(valid),
+		)
+	}
+	return valid
+}
+
+// schemaProblems returns the problems found checking that the job retention
+// policy and, for each measurement in ms, the measurement, field and tags exist
+// in the database
+func (r *oldSeriesRun) schemaProblems(db string, ms []string) ([]schemaProblem, error) {
+	var (
+		oc           = r.oc
+		problems     []schemaProblem
+		rps          []string
+		measurements []string
+		fieldKeys    []string
+		tagKeys      []string
+		err          error
+	)
+
+	if len(oc.Rp) > 0 {
+		if rps, err = r.ic.QueryShowRetentionPolicies(db); err != nil {
+			return nil, err
+		}
+		if !sliceplus.Contains(rps, oc.Rp) {
+			var err = fmt.Errorf("retention policy %s not found in %s db", oc.Rp, db)
+			problems = append(problems, schemaProblem{"", err})
+		}
+	}
+	if measurements, err = r.ic.QueryShowMeasurements(db, ""); err != nil {
+		return nil, err
+	}
+	for _, m := range ms {
+		if !sliceplus.Contains(measurements, m) {
+			var err = fmt.Errorf("measurement %s not found in %s db", m, db)
+			problems = append(problems, schemaProblem{m, err})
+			continue
+		}
+		var field = oc.Field
+		if f, ok := oc.Fields[m]; ok && len(f) > 0 {
+			field = f
+		}
+		switch field {
+		case "", "auto", "*":
+		default:
+			if fieldKeys, err = r.ic.QueryShowFieldKeys(db, oc.Rp, m); err != nil {
+				return nil, err
+			}
+			if !sliceplus.Contains(fieldKeys, field) {
+				problems = append(problems, schemaProblem{m, fmt.Errorf("field %s not found in measurement %s of %s db",
+					field,
+					m,
+					db,
+				)})
+			}
+		}
+		if tagKeys, err = r.ic.QueryShowTagKeys(db, oc.Rp, m); err != nil {
+			return nil, err
+		}
+		for _, tag := range oc.Tags {
+			if !sliceplus.Contains(tagKeys, tag) {
+				problems = append(problems, schemaProblem{m, fmt.Errorf("tag %s not found in measurement %s of %s db",
+					tag,
+					m,
+					db,
+				)})
+			}
+		}
+	}
+	return problems, nil
+}
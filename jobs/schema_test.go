@@ -0,0 +1,113 @@
+// influxclean jobs package is responsible for launching queries and drops
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+package jobs
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tesibelda/influxclean/config"
+)
+
+func TestPreflight(t *testing.T) {
+	var tests = []struct {
+		name string
+		job  config.OldSeriesInfo
+		want []string
+		kind ErrorKind
+	}{
+		{
+			name: "all measurements valid",
+			job:  config.OldSeriesInfo{Measurements: []string{"cpu", "mem"}, Tags: []string{"host"}},
+			want: []string{"cpu", "mem"},
+		},
+		{
+			name: "missing field skips measurement",
+			job: config.OldSeriesInfo{
+				Measurements: []string{"cpu", "mem"},
+				Fields:       map[string]string{"mem": "used", "cpu": "nofield"},
+				Tags:         []string{"host"},
+			},
+			want: []string{"mem"},
+		},
+		{
+			name: "missing tag skips all",
+			job:  config.OldSeriesInfo{Measurements: []string{"cpu", "mem"}, Tags: []string{"container_name"}},
+			kind: KindAborted,
+		},
+		{
+			name: "missing retention policy skips db",
+			job:  config.OldSeriesInfo{Rp: "weekly", Measurements: []string{"cpu", "mem"}, Tags: []string{"host"}},
+			kind: KindAborted,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r, _ = newFakeRun(t, tt.job, map[string]string{
+				"SHOW RETENTION POLICIES":    showResponse("name", "autogen"),
+				"SHOW MEASUREMENTS":          showResponse("name", "cpu", "mem", "disk"),
+				`SHOW FIELD KEYS FROM "cpu"`: showResponse("fieldKey", "usage_idle"),
+				`SHOW FIELD KEYS FROM "mem"`: showResponse("fieldKey", "used"),
+				`SHOW TAG KEYS FROM "cpu"`:   showResponse("tagKey", "cpu", "host"),
+				`SHOW TAG KEYS FROM "mem"`:   showResponse("tagKey", "host"),
+			})
+			var got = r.preflight("telegraf", tt.job.Measurements)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("preflight() = %v, want %v", got, tt.want)
+			}
+			switch {
+			case tt.kind == 0 && len(r.rep.Failures) > 0:
+				t.Errorf("preflight() failures = %v", r.rep.Failures)
+			case tt.kind != 0 && !r.rep.Has(tt.kind):
+				t.Errorf("preflight() failures = %v, want kind %s", r.rep.Failures, tt.kind)
+			}
+		})
+	}
+}
+
+func TestRunQuotesMeasurements(t *testing.T) {
+	var job = config.OldSeriesInfo{
+		Name:              "quoted",
+		Databases:         []string{"telegraf"},
+		Measurements:      []string{`"cpu.total"`},
+		Measurement_regex: "^disk",
+		Field:             "auto",
+		Tags:              []string{"host"},
+		History_window:    []string{"0m", "0m"},
+		Current_window:    []string{"72h", "1m"},
+	}
+	var r, fs = newFakeRun(t, job, map[string]string{
+		"SHOW MEASUREMENTS": showResponse("name", "cpu.total", "disk-io"),
+		"SHOW FIELD KEYS":   showResponse("fieldKey", "value"),
+		"SHOW TAG KEYS":     showResponse("tagKey", "host"),
+		"SHOW TAG VALUES":   showResponse("value", "h1"),
+	})
+	r.dryrun = false
+	r.ic.SetDryrun(false)
+	if n := r.run(); n > 0 {
+		t.Fatalf("run() failures = %v", r.rep.Failures)
+	}
+	var want = map[string]bool{
+		`SHOW FIELD KEYS FROM "cpu.total"`:                      false,
+		`SHOW TAG VALUES FROM "disk-io" WITH KEY=host`:          false,
+		`SELECT first("value") FROM "disk-io" WHERE`:            false,
+		`SELECT first("value") FROM "cpu.total" WHERE (time > `: false,
+		`DROP SERIES FROM "disk-io" WHERE host='h1'`:            false,
+	}
+	for _, q := range fs.queries {
+		for prefix := range want {
+			if strings.HasPrefix(q, prefix) {
+				want[prefix] = true
+			}
+		}
+	}
+	for prefix, found := range want {
+		if !found {
+			t.Errorf("no query %s... in %q", prefix, fs.queries)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+// influxclean jobs package is responsible for launching queries and drops
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+package jobs
+
+import (
+	"fmt"
+
+	"github.com/tesibelda/influxclean/config"
+	"github.com/tesibelda/influxclean/datastore/influxdb1"
+	"github.com/tesibelda/influxclean/internal/redact"
+	"github.com/tesibelda/influxclean/internal/sliceplus"
+	"github.com/tesibelda/influxclean/log"
+)
+
+// ValidateOnline connects to each server in the configuration and returns the
+// problems found checking that the databases, retention policies, measurements,
+// fields and tags used by its jobs exist
+func ValidateOnline(cfg *config.InfluxCleanConfig, lo *log.Logger) []config.Problem {
+	var problems []config.Problem
+
+	l = lo
+	l.AddSecrets(cfg.Secrets()...)
+	for _, inf := range cfg.Influxdb1 {
+		var srv = redact.URL(inf.Url)
+		var ic = &influxdb1.Influxdb1Client{Log: l}
+		var err = ic.Open(inf.Url, inf.User, inf.Password, inf.SkipsVerify(), true)
+		if err != nil {
+			problems = append(problems, config.Problem{
+				File:   inf.Source.File,
+				Line:   inf.Source.Line,
+				Server: srv,
+				Err:    fmt.Errorf("could not connect: %w", err),
+			})
+			continue
+		}
+		for _, job := range inf.Oldseries {
+			for _, err = range validateOldSeriesOnline(ic, job) {
+				problems = append(problems, config.Problem{
+					File:   job.Source.File,
+					Line:   job.Source.Line,
+					Server: srv,
+					Job:    job.Name,
+					Err:    err,
+				})
+			}
+		}
+		ic.Close()
+	}
+	return problems
+}
+
+// validateOldSeriesOnline returns the schema problems of an oldseries job
+func validateOldSeriesOnline(ic *influxdb1.Influxdb1Client, oc config.OldSeriesInfo) []error {
+	var (
+		r        = &oldSeriesRun{ic: ic, oc: oc, fields: map[string][]string{}, log: ic.Log}
+		problems []error
+	)
+
+	dbs, err := ic.QueryShowDatabases()
+	if err != nil {
+		return []error{err}
+	}
+	for _, db := range oc.Databases {
+		if !sliceplus.Contains(dbs, db) {
+			problems = append(problems, fmt.Errorf("database %s not found", db))
+		}
+	}
+	if len(oc.Databases) == 0 {
+		oc.Databases = dbs
+	}
+	for _, db := range sliceplus.Intersection(oc.Databases, dbs) {
+		ms, err := r.measurements(db)
+		if err != nil {
+			problems = append(problems, err)
+			continue
+		}
+		dbproblems, err := r.schemaProblems(db, ms)
+		if err != nil {
+			problems = append(problems, err)
+			continue
+		}
+		for _, p := range dbproblems {
+			problems = append(problems, p.err)
+		}
+	}
+	return problems
+}
@@ -0,0 +1,117 @@
+// influxclean log package provides log abstraction
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+//go:build linux
+
+package log
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// journalSocket is the socket of the journald native protocol
+const journalSocket = "/run/systemd/journal/socket"
+
+// journaldHook sends log entries to journald with their fields as journal fields
+type journaldHook struct {
+	conn *net.UnixConn
+	addr *net.UnixAddr
+	tag  string
+}
+
+// newJournaldHook returns a hook sending entries to the local journald
+func newJournaldHook(tag string) (*journaldHook, error) {
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Net: "unixgram"})
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to journald: %w", err)
+	}
+	var addr = &net.UnixAddr{Name: journalSocket, Net: "unixgram"}
+	if _, err = conn.WriteToUnix(nil, addr); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("could not connect to journald: %w", err)
+	}
+	return &journaldHook{conn: conn, addr: addr, tag: tag}, nil
+}
+
+// Levels returns the levels the hook fires for
+func (h *journaldHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire sends an entry to journald, with its message, priority, identifier and
+// fields named in upper case
+func (h *journaldHook) Fire(e *logrus.Entry) error {
+	var buf bytes.Buffer
+	journalField(&buf, "MESSAGE", e.Message)
+	journalField(&buf, "PRIORITY", journalPriority(e.Level))
+	journalField(&buf, "SYSLOG_IDENTIFIER", h.tag)
+
+	var keys = make([]string, 0, len(e.Data))
+	for k := range e.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		journalField(&buf, journalName(k), fmt.Sprint(e.Data[k]))
+	}
+	_, err := h.conn.WriteToUnix(buf.Bytes(), h.addr)
+	return err
+}
+
+// Close closes the journald socket
+func (h *journaldHook) Close() error {
+	return h.conn.Close()
+}
+
+// journalField writes a field in the journald native format, using the binary
+// form for values with new lines
+func journalField(buf *bytes.Buffer, name, value string) {
+	if !strings.Contains(value, "\n") {
+		fmt.Fprintf(buf, "%s=%s\n", name, value)
+		return
+	}
+	buf.WriteString(name)
+	buf.WriteByte('\n')
+	binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+// journalName returns a valid journal field name for a log field, in upper case
+// with characters other than letters and digits replaced by underscores
+func journalName(k string) string {
+	var name = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, k)
+	return name
+}
+
+// journalPriority returns the syslog priority of a log level
+func journalPriority(level logrus.Level) string {
+	switch level {
+	case logrus.PanicLevel, logrus.FatalLevel:
+		return "2"
+	case logrus.ErrorLevel:
+		return "3"
+	case logrus.WarnLevel:
+		return "4"
+	case logrus.InfoLevel:
+		return "6"
+	}
+	return "7"
+}
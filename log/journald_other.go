@@ -0,0 +1,34 @@
+// influxclean log package provides log abstraction
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+//go:build !linux
+
+package log
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// journaldHook is not available on this platform
+type journaldHook struct{}
+
+// newJournaldHook returns an error as journald is only available on linux
+func newJournaldHook(tag string) (*journaldHook, error) {
+	return nil, fmt.Errorf("journald output is only supported on linux")
+}
+
+func (h *journaldHook) Levels() []logrus.Level {
+	return nil
+}
+
+func (h *journaldHook) Fire(e *logrus.Entry) error {
+	return nil
+}
+
+func (h *journaldHook) Close() error {
+	return nil
+}
@@ -6,58 +6,262 @@
 package log
 
 import (
+	"fmt"
+	"io"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
 type Logger struct {
-	log   *logrus.Logger
-	debug bool
+	log     *logrus.Logger
+	entry   *logrus.Entry
+	debug   bool
+	secrets *[]string
+	closer  io.Closer
 }
 
+// Fields are the structured fields added to log entries
+type Fields map[string]interface{}
+
+// Options configures the format, level and output of a Logger
+type Options struct {
+	Format     string // text, json or logfmt
+	Level      string // trace, debug, info, warn or error
+	Output     string // stdout, file, syslog or journald, default file if File is set
+	File       string // file to log to with file output
+	MaxSize    int64  // size in bytes at which the log file is rotated, 0 never
+	MaxBackups int    // number of rotated log files kept
+	Syslog     string // syslog address as udp://host:port, tcp://host:port or unix:///path, local if empty
+	Facility   string // syslog facility, default daemon
+	Tag        string // syslog tag and journald identifier, default influxclean
+}
+
+// Log formats
+const (
+	FormatText   = "text"
+	FormatJSON   = "json"
+	FormatLogfmt = "logfmt"
+)
+
+// Log outputs
+const (
+	OutputStdout   = "stdout"
+	OutputFile     = "file"
+	OutputSyslog   = "syslog"
+	OutputJournald = "journald"
+)
+
+// Redacted replaces secrets in log messages
+const Redacted = "<redacted>"
+
 func NewLogger(debug bool) *Logger {
-	var l = &Logger{
-		debug: debug,
-	}
-	var log = logrus.New()
-	log.SetOutput(os.Stdout)
-	log.SetFormatter(&logrus.TextFormatter{
-		DisableColors:   true,
-		FullTimestamp:   false,
-		TimestampFormat: "2006/01/02 15:04:05",
-	})
-	l.log = log
+	var level = "info"
 	if debug {
-		log.SetLevel(logrus.DebugLevel)
+		level = "debug"
 	}
+	var l, _ = New(Options{Level: level})
 	return l
 }
 
+// CheckOptions returns an error if the format, level or output of the options
+// is unknown
+func CheckOptions(o Options) error {
+	switch o.Format {
+	case "", FormatText, FormatLogfmt, FormatJSON:
+	default:
+		return fmt.Errorf("unknown log format %s, use text, json or logfmt", o.Format)
+	}
+	if len(o.Level) > 0 {
+		level, err := logrus.ParseLevel(o.Level)
+		if err != nil || level < logrus.ErrorLevel {
+			return fmt.Errorf("unknown log level %s, use trace, debug, info, warn or error", o.Level)
+		}
+	}
+	switch o.Output {
+	case "", OutputStdout, OutputFile, OutputSyslog, OutputJournald:
+	default:
+		return fmt.Errorf("unknown log output %s, use stdout, file, syslog or journald", o.Output)
+	}
+	if o.Output == OutputFile && len(o.File) == 0 {
+		return fmt.Errorf("file log output needs a log file")
+	}
+	return nil
+}
+
+// New returns a Logger with the given options
+func New(o Options) (*Logger, error) {
+	if err := CheckOptions(o); err != nil {
+		return nil, err
+	}
+	var log = logrus.New()
+	var l = &Logger{
+		log:     log,
+		entry:   logrus.NewEntry(log),
+		secrets: &[]string{},
+	}
+
+	switch o.Format {
+	case "", FormatText:
+		log.SetFormatter(&logrus.TextFormatter{
+			DisableColors:   true,
+			FullTimestamp:   false,
+			TimestampFormat: "2006/01/02 15:04:05",
+		})
+	case FormatLogfmt:
+		log.SetFormatter(&logrus.TextFormatter{
+			DisableColors:   true,
+			FullTimestamp:   true,
+			TimestampFormat: time.RFC3339Nano,
+		})
+	case FormatJSON:
+		log.SetFormatter(&logrus.JSONFormatter{TimestampFormat: time.RFC3339Nano})
+	}
+
+	if len(o.Level) == 0 {
+		o.Level = "info"
+	}
+	level, _ := logrus.ParseLevel(o.Level)
+	log.SetLevel(level)
+	l.debug = level >= logrus.DebugLevel
+
+	if len(o.Output) == 0 {
+		o.Output = OutputStdout
+		if len(o.File) > 0 {
+			o.Output = OutputFile
+		}
+	}
+	if len(o.Tag) == 0 {
+		o.Tag = "influxclean"
+	}
+	switch o.Output {
+	case OutputStdout:
+		log.SetOutput(os.Stdout)
+	case OutputFile:
+		f, err := openRotating(o.File, o.MaxSize, o.MaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		log.SetOutput(f)
+		l.closer = f
+	case OutputSyslog:
+		h, err := newSyslogHook(o.Syslog, o.Facility, o.Tag)
+		if err != nil {
+			return nil, err
+		}
+		disableTimestamp(log.Formatter)
+		log.AddHook(h)
+		log.SetOutput(io.Discard)
+		l.closer = h
+	case OutputJournald:
+		h, err := newJournaldHook(o.Tag)
+		if err != nil {
+			return nil, err
+		}
+		log.AddHook(h)
+		log.SetOutput(io.Discard)
+		l.closer = h
+	}
+	return l, nil
+}
+
+// disableTimestamp removes the timestamp from formatted entries, as syslog
+// adds its own
+func disableTimestamp(f logrus.Formatter) {
+	switch t := f.(type) {
+	case *logrus.TextFormatter:
+		t.DisableTimestamp = true
+	case *logrus.JSONFormatter:
+		t.DisableTimestamp = true
+	}
+}
+
 func (l *Logger) SetLevel(level logrus.Level) {
 	l.log.SetLevel(level)
 }
 
+// Close closes the log file, if any
+func (l *Logger) Close() error {
+	if l.closer == nil {
+		return nil
+	}
+	return l.closer.Close()
+}
+
+// With returns a Logger adding the given fields to its entries, which shares
+// output and secrets with l
+func (l *Logger) With(fields Fields) *Logger {
+	var wl = *l
+	var rf = make(logrus.Fields, len(fields))
+	for k, v := range fields {
+		rf[k] = v
+	}
+	wl.entry = l.entry.WithFields(rf)
+	return &wl
+}
+
+// AddSecrets registers secrets to be redacted from all log messages
+func (l *Logger) AddSecrets(secrets ...string) {
+	for _, s := range secrets {
+		if len(s) > 0 {
+			*l.secrets = append(*l.secrets, s)
+		}
+	}
+}
+
+// redact replaces registered secrets in a log message
+func (l *Logger) redact(msg string) string {
+	for _, s := range *l.secrets {
+		msg = strings.ReplaceAll(msg, s, Redacted)
+	}
+	return msg
+}
+
+// entryRedacted returns the entry with secrets replaced in its string fields
+func (l *Logger) entryRedacted() *logrus.Entry {
+	if len(*l.secrets) == 0 {
+		return l.entry
+	}
+	var fields = make(logrus.Fields, len(l.entry.Data))
+	for k, v := range l.entry.Data {
+		if s, ok := v.(string); ok {
+			v = l.redact(s)
+		}
+		fields[k] = v
+	}
+	return l.entry.Logger.WithFields(fields)
+}
+
+func (l *Logger) Trace(template string) {
+	l.entryRedacted().Trace(l.redact(template))
+}
+
+func (l *Logger) Tracef(template string, args ...interface{}) {
+	l.entryRedacted().Trace(l.redact(fmt.Sprintf(template, args...)))
+}
+
 func (l *Logger) Debug(template string) {
-	l.log.Debug(template)
+	l.entryRedacted().Debug(l.redact(template))
 }
 
 func (l *Logger) Debugf(template string, args ...interface{}) {
-	l.log.Debugf(template, args...)
+	l.entryRedacted().Debug(l.redact(fmt.Sprintf(template, args...)))
 }
 
 func (l *Logger) Info(template string) {
-	l.log.Info(template)
+	l.entryRedacted().Info(l.redact(template))
 }
 
 func (l *Logger) Infof(template string, args ...interface{}) {
-	l.log.Infof(template, args...)
+	l.entryRedacted().Info(l.redact(fmt.Sprintf(template, args...)))
 }
 
 func (l *Logger) Warnf(template string, args ...interface{}) {
-	l.log.Warnf(template, args...)
+	l.entryRedacted().Warn(l.redact(fmt.Sprintf(template, args...)))
 }
 
 func (l *Logger) Errorf(template string, args ...interface{}) {
-	l.log.Errorf(template, args...)
+	l.entryRedacted().Error(l.redact(fmt.Sprintf(template, args...)))
 }
@@ -0,0 +1,87 @@
+// influxclean log package provides log abstraction
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+package log
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// rotatingFile is a log file renamed to name.1 when it reaches maxSize bytes,
+// shifting older files up to name.maxBackups
+type rotatingFile struct {
+	mu         sync.Mutex
+	name       string
+	maxSize    int64
+	maxBackups int
+	f          *os.File
+	size       int64
+}
+
+// openRotating opens a log file for appending, rotating it at maxSize bytes
+// unless maxSize is 0
+func openRotating(name string, maxSize int64, maxBackups int) (*rotatingFile, error) {
+	var rf = &rotatingFile{name: name, maxSize: maxSize, maxBackups: maxBackups}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+// open opens the log file and gets its size
+func (rf *rotatingFile) open() error {
+	f, err := os.OpenFile(rf.name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o640)
+	if err != nil {
+		return fmt.Errorf("could not open log file: %w", err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("could not open log file: %w", err)
+	}
+	rf.f, rf.size = f, fi.Size()
+	return nil
+}
+
+// Write writes p to the log file, rotating it first if p does not fit
+func (rf *rotatingFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if rf.maxSize > 0 && rf.size > 0 && rf.size+int64(len(p)) > rf.maxSize {
+		if err := rf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := rf.f.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+// rotate closes the log file, shifts the backups and opens a new file
+func (rf *rotatingFile) rotate() error {
+	rf.f.Close()
+	if rf.maxBackups < 1 {
+		os.Remove(rf.name)
+		return rf.open()
+	}
+	os.Remove(fmt.Sprintf("%s.%d", rf.name, rf.maxBackups))
+	for i := rf.maxBackups - 1; i > 0; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", rf.name, i), fmt.Sprintf("%s.%d", rf.name, i+1))
+	}
+	if err := os.Rename(rf.name, rf.name+".1"); err != nil {
+		return fmt.Errorf("could not rotate log file: %w", err)
+	}
+	return rf.open()
+}
+
+// Close closes the log file
+func (rf *rotatingFile) Close() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	return rf.f.Close()
+}
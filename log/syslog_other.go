@@ -0,0 +1,34 @@
+// influxclean log package provides log abstraction
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+//go:build windows || plan9
+
+package log
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// syslogHook is not available on this platform
+type syslogHook struct{}
+
+// newSyslogHook returns an error as syslog is not supported on this platform
+func newSyslogHook(address, facility, tag string) (*syslogHook, error) {
+	return nil, fmt.Errorf("syslog output is not supported on this platform")
+}
+
+func (h *syslogHook) Levels() []logrus.Level {
+	return nil
+}
+
+func (h *syslogHook) Fire(e *logrus.Entry) error {
+	return nil
+}
+
+func (h *syslogHook) Close() error {
+	return nil
+}
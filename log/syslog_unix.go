@@ -0,0 +1,102 @@
+// influxclean log package provides log abstraction
+//
+// Author: Tesifonte Belda
+// License: The MIT License (MIT)
+
+//go:build !windows && !plan9
+
+package log
+
+import (
+	"fmt"
+	"log/syslog"
+	"net/url"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// syslogFacilities maps facility names to their syslog priority
+var syslogFacilities = map[string]syslog.Priority{
+	"kern":   syslog.LOG_KERN,
+	"user":   syslog.LOG_USER,
+	"daemon": syslog.LOG_DAEMON,
+	"auth":   syslog.LOG_AUTH,
+	"syslog": syslog.LOG_SYSLOG,
+	"cron":   syslog.LOG_CRON,
+	"local0": syslog.LOG_LOCAL0,
+	"local1": syslog.LOG_LOCAL1,
+	"local2": syslog.LOG_LOCAL2,
+	"local3": syslog.LOG_LOCAL3,
+	"local4": syslog.LOG_LOCAL4,
+	"local5": syslog.LOG_LOCAL5,
+	"local6": syslog.LOG_LOCAL6,
+	"local7": syslog.LOG_LOCAL7,
+}
+
+// syslogHook sends log entries to syslog with the severity of their level
+type syslogHook struct {
+	w *syslog.Writer
+}
+
+// newSyslogHook connects to the syslog at address, which is the local syslog
+// if empty or else udp://host:port, tcp://host:port or unix:///path
+func newSyslogHook(address, facility, tag string) (*syslogHook, error) {
+	var network, raddr string
+	if len(address) > 0 {
+		u, err := url.Parse(address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid syslog address %s: %w", address, err)
+		}
+		switch u.Scheme {
+		case "udp", "tcp":
+			network, raddr = u.Scheme, u.Host
+		case "unix", "unixgram":
+			network, raddr = u.Scheme, u.Path
+		default:
+			return nil, fmt.Errorf("invalid syslog address %s, use udp://, tcp:// or unix://", address)
+		}
+	}
+	if len(facility) == 0 {
+		facility = "daemon"
+	}
+	prio, ok := syslogFacilities[strings.ToLower(facility)]
+	if !ok {
+		return nil, fmt.Errorf("unknown syslog facility %s", facility)
+	}
+	w, err := syslog.Dial(network, raddr, prio|syslog.LOG_INFO, tag)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to syslog: %w", err)
+	}
+	return &syslogHook{w: w}, nil
+}
+
+// Levels returns the levels the hook fires for
+func (h *syslogHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire sends an entry formatted by its logger formatter to syslog
+func (h *syslogHook) Fire(e *logrus.Entry) error {
+	b, err := e.Logger.Formatter.Format(e)
+	if err != nil {
+		return err
+	}
+	var msg = strings.TrimRight(string(b), "\n")
+	switch e.Level {
+	case logrus.PanicLevel, logrus.FatalLevel:
+		return h.w.Crit(msg)
+	case logrus.ErrorLevel:
+		return h.w.Err(msg)
+	case logrus.WarnLevel:
+		return h.w.Warning(msg)
+	case logrus.InfoLevel:
+		return h.w.Info(msg)
+	}
+	return h.w.Debug(msg)
+}
+
+// Close closes the syslog connection
+func (h *syslogHook) Close() error {
+	return h.w.Close()
+}